@@ -0,0 +1,22 @@
+BEGIN;
+
+-- Pending and failed users have no personal data, they are not deleted silently.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users WHERE enrichment_status <> 'completed') THEN
+        RAISE EXCEPTION 'users have pending or failed enrichment, complete or delete them before rollback';
+    END IF;
+END;
+$$;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_name_check;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_surname_check;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_address_check;
+
+ALTER TABLE users ADD CONSTRAINT users_name_check    CHECK (name <> '');
+ALTER TABLE users ADD CONSTRAINT users_surname_check CHECK (surname <> '');
+ALTER TABLE users ADD CONSTRAINT users_address_check CHECK (address <> '');
+
+ALTER TABLE users DROP COLUMN IF EXISTS enrichment_status;
+
+COMMIT;
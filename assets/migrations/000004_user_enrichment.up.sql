@@ -0,0 +1,16 @@
+BEGIN;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS enrichment_status TEXT NOT NULL DEFAULT 'completed'
+        CHECK (enrichment_status IN ('pending', 'completed', 'failed'));
+
+-- Pending and failed users have only passport data, personal data is filled later.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_name_check;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_surname_check;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_address_check;
+
+ALTER TABLE users ADD CONSTRAINT users_name_check    CHECK (name <> ''    OR enrichment_status <> 'completed');
+ALTER TABLE users ADD CONSTRAINT users_surname_check CHECK (surname <> '' OR enrichment_status <> 'completed');
+ALTER TABLE users ADD CONSTRAINT users_address_check CHECK (address <> '' OR enrichment_status <> 'completed');
+
+COMMIT;
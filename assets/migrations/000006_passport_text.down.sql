@@ -0,0 +1,12 @@
+BEGIN;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_passport_serie_check;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_passport_number_check;
+
+ALTER TABLE users ALTER COLUMN passport_serie  TYPE INTEGER USING passport_serie::INTEGER;
+ALTER TABLE users ALTER COLUMN passport_number TYPE INTEGER USING passport_number::INTEGER;
+
+ALTER TABLE users ADD CONSTRAINT users_passport_serie_check  CHECK (passport_serie > 0);
+ALTER TABLE users ADD CONSTRAINT users_passport_number_check CHECK (passport_number > 0);
+
+COMMIT;
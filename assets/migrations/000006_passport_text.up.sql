@@ -0,0 +1,28 @@
+BEGIN;
+
+-- Passport serie and number are digit strings, leading zeros are significant.
+-- lpad truncates longer values, they must be fixed by hand instead of being cut silently.
+DO $$
+DECLARE
+    invalid INTEGER;
+BEGIN
+    SELECT count(*) INTO invalid
+    FROM users
+    WHERE length(passport_serie::TEXT) > 4 OR length(passport_number::TEXT) > 6;
+
+    IF invalid > 0 THEN
+        RAISE EXCEPTION '% users have passport serie longer than 4 or number longer than 6 digits, fix them before the migration', invalid;
+    END IF;
+END;
+$$;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_passport_serie_check;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_passport_number_check;
+
+ALTER TABLE users ALTER COLUMN passport_serie  TYPE TEXT USING lpad(passport_serie::TEXT, 4, '0');
+ALTER TABLE users ALTER COLUMN passport_number TYPE TEXT USING lpad(passport_number::TEXT, 6, '0');
+
+ALTER TABLE users ADD CONSTRAINT users_passport_serie_check  CHECK (passport_serie ~ '^[0-9]{4}$');
+ALTER TABLE users ADD CONSTRAINT users_passport_number_check CHECK (passport_number ~ '^[0-9]{6}$');
+
+COMMIT;
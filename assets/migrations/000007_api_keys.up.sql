@@ -0,0 +1,18 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    name     TEXT NOT NULL CHECK (name <> ''),
+    prefix   TEXT NOT NULL CHECK (prefix <> ''),
+    key_hash TEXT NOT NULL CHECK (key_hash <> ''),
+
+    revoked_at TIMESTAMPTZ,
+
+    CONSTRAINT unique_key_hash UNIQUE (key_hash)
+);
+
+COMMIT;
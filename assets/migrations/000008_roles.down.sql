@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS user_id;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+
+COMMIT;
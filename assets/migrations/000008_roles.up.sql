@@ -0,0 +1,11 @@
+BEGIN;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'employee'
+        CHECK (role IN ('admin', 'manager', 'employee'));
+
+-- Keys without user are service keys with admin rights.
+ALTER TABLE api_keys
+    ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users (id) ON DELETE CASCADE;
+
+COMMIT;
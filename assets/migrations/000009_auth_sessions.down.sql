@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS auth_sessions;
+DROP TABLE IF EXISTS login_codes;
+
+COMMIT;
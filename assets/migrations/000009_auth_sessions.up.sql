@@ -0,0 +1,43 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS login_codes (
+    id SERIAL PRIMARY KEY,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    user_id   INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL CHECK (code_hash <> ''),
+
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+
+    CONSTRAINT unique_code_hash UNIQUE (code_hash)
+);
+
+CREATE TABLE IF NOT EXISTS auth_sessions (
+    id SERIAL PRIMARY KEY,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+-- Refresh tokens are rotated, every used token stays to detect reuse.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id SERIAL PRIMARY KEY,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    session_id INTEGER NOT NULL REFERENCES auth_sessions (id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL CHECK (token_hash <> ''),
+
+    used_at TIMESTAMPTZ,
+
+    CONSTRAINT unique_token_hash UNIQUE (token_hash)
+);
+
+COMMIT;
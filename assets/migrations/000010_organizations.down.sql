@@ -0,0 +1,27 @@
+BEGIN;
+
+-- Data of other organizations can not be merged into a single tenant, it is not deleted silently.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users WHERE organization_id <> 1)
+        OR EXISTS (SELECT 1 FROM sessions WHERE organization_id <> 1)
+        OR EXISTS (SELECT 1 FROM api_keys WHERE organization_id <> 1)
+        OR EXISTS (SELECT 1 FROM login_codes WHERE organization_id <> 1)
+        OR EXISTS (SELECT 1 FROM auth_sessions WHERE organization_id <> 1) THEN
+        RAISE EXCEPTION 'organizations other than default have data, move or delete it before rollback';
+    END IF;
+END;
+$$;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS unique_passport;
+ALTER TABLE users ADD CONSTRAINT unique_passport UNIQUE (passport_serie, passport_number);
+
+ALTER TABLE auth_sessions DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE login_codes   DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE api_keys      DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE sessions      DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE users         DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organizations;
+
+COMMIT;
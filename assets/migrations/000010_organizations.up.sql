@@ -0,0 +1,38 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id SERIAL PRIMARY KEY,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    name TEXT NOT NULL CHECK (name <> ''),
+
+    CONSTRAINT unique_organization_name UNIQUE (name)
+);
+
+-- Existing data is moved to the default organization.
+INSERT INTO organizations (id, name) VALUES (1, 'default') ON CONFLICT DO NOTHING;
+SELECT setval(pg_get_serial_sequence('organizations', 'id'), (SELECT max(id) FROM organizations));
+
+ALTER TABLE users         ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations (id) ON DELETE CASCADE;
+ALTER TABLE sessions      ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations (id) ON DELETE CASCADE;
+ALTER TABLE api_keys      ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations (id) ON DELETE CASCADE;
+ALTER TABLE login_codes   ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations (id) ON DELETE CASCADE;
+ALTER TABLE auth_sessions ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations (id) ON DELETE CASCADE;
+
+ALTER TABLE users         ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE sessions      ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE api_keys      ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE login_codes   ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE auth_sessions ALTER COLUMN organization_id DROP DEFAULT;
+
+CREATE INDEX IF NOT EXISTS users_organization_id_idx    ON users (organization_id);
+CREATE INDEX IF NOT EXISTS sessions_organization_id_idx ON sessions (organization_id);
+CREATE INDEX IF NOT EXISTS api_keys_organization_id_idx ON api_keys (organization_id);
+
+-- Passport is unique within the organization.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS unique_passport;
+ALTER TABLE users ADD CONSTRAINT unique_passport UNIQUE (organization_id, passport_serie, passport_number);
+
+COMMIT;
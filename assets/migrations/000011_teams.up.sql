@@ -0,0 +1,31 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS teams (
+    id SERIAL PRIMARY KEY,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    organization_id INTEGER NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+
+    name TEXT NOT NULL CHECK (name <> ''),
+
+    CONSTRAINT unique_team_name UNIQUE (organization_id, name)
+);
+
+-- Managers of a team can review its members.
+CREATE TABLE IF NOT EXISTS team_members (
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    team_id INTEGER NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+
+    role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('member', 'manager')),
+
+    PRIMARY KEY (team_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS team_members_user_id_idx ON team_members (user_id);
+
+COMMIT;
@@ -0,0 +1,37 @@
+BEGIN;
+
+-- Audit log is kept when audited entities are deleted, so there are no foreign keys.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    organization_id INTEGER NOT NULL,
+
+    actor_api_key_id INTEGER,
+    actor_user_id    INTEGER,
+
+    action    TEXT    NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+    entity    TEXT    NOT NULL CHECK (entity <> ''),
+    entity_id INTEGER NOT NULL,
+
+    before JSONB,
+    after  JSONB,
+
+    trace_id TEXT
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_organization_id_created_at_idx ON audit_log (organization_id, created_at);
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity, entity_id);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+COMMIT;
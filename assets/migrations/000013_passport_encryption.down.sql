@@ -0,0 +1,26 @@
+BEGIN;
+
+-- Encrypted passports can not be decrypted by the database.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users WHERE passport_serie IS NULL OR passport_number IS NULL) THEN
+        RAISE EXCEPTION 'users have encrypted passports, decrypt them before the migration';
+    END IF;
+END;
+$$;
+
+DROP INDEX IF EXISTS users_passport_number_hash_idx;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS unique_passport_hash;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_passport_check;
+
+ALTER TABLE users ALTER COLUMN passport_serie  SET NOT NULL;
+ALTER TABLE users ALTER COLUMN passport_number SET NOT NULL;
+
+ALTER TABLE users DROP COLUMN IF EXISTS passport_number_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS passport_serie_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS passport_number_enc;
+ALTER TABLE users DROP COLUMN IF EXISTS passport_serie_enc;
+ALTER TABLE users DROP COLUMN IF EXISTS passport_key_id;
+
+COMMIT;
@@ -0,0 +1,29 @@
+BEGIN;
+
+-- Passport is encrypted by the application, *_hash columns are blind indexes for lookups and uniqueness.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS passport_key_id      INTEGER;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS passport_serie_enc   TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS passport_number_enc  TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS passport_serie_hash  TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS passport_number_hash TEXT;
+
+-- Plain text columns are cleared by the application when it encrypts existing rows on startup.
+ALTER TABLE users ALTER COLUMN passport_serie  DROP NOT NULL;
+ALTER TABLE users ALTER COLUMN passport_number DROP NOT NULL;
+
+ALTER TABLE users ADD CONSTRAINT users_passport_check CHECK (
+    (passport_serie IS NOT NULL AND passport_number IS NOT NULL) OR
+    (
+        passport_key_id      IS NOT NULL AND
+        passport_serie_enc   IS NOT NULL AND
+        passport_number_enc  IS NOT NULL AND
+        passport_serie_hash  IS NOT NULL AND
+        passport_number_hash IS NOT NULL
+    )
+);
+
+ALTER TABLE users ADD CONSTRAINT unique_passport_hash UNIQUE (organization_id, passport_serie_hash, passport_number_hash);
+
+CREATE INDEX IF NOT EXISTS users_passport_number_hash_idx ON users (organization_id, passport_number_hash);
+
+COMMIT;
@@ -0,0 +1,31 @@
+BEGIN;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users WHERE erased_at IS NOT NULL) THEN
+        RAISE EXCEPTION 'users contain erased personal data, they must be deleted before rollback';
+    END IF;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_passport_check;
+ALTER TABLE users ADD CONSTRAINT users_passport_check CHECK (
+    (passport_serie IS NOT NULL AND passport_number IS NOT NULL) OR
+    (
+        passport_key_id      IS NOT NULL AND
+        passport_serie_enc   IS NOT NULL AND
+        passport_number_enc  IS NOT NULL AND
+        passport_serie_hash  IS NOT NULL AND
+        passport_number_hash IS NOT NULL
+    )
+);
+
+ALTER TABLE users DROP COLUMN IF EXISTS erased_at;
+
+COMMIT;
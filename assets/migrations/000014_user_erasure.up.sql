@@ -0,0 +1,38 @@
+BEGIN;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS erased_at TIMESTAMPTZ;
+
+-- Erased users keep neither plain nor encrypted passport.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_passport_check;
+ALTER TABLE users ADD CONSTRAINT users_passport_check CHECK (
+    erased_at IS NOT NULL OR
+    (passport_serie IS NOT NULL AND passport_number IS NOT NULL) OR
+    (
+        passport_key_id      IS NOT NULL AND
+        passport_serie_enc   IS NOT NULL AND
+        passport_number_enc  IS NOT NULL AND
+        passport_serie_hash  IS NOT NULL AND
+        passport_number_hash IS NOT NULL
+    )
+);
+
+-- Redaction of personal data on erasure is the only allowed change of the audit log:
+-- it must be enabled in the transaction and may change only entity states.
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+        AND current_setting('audit_log.redaction', true) = 'on'
+        AND (NEW.id, NEW.created_at, NEW.organization_id, NEW.actor_api_key_id, NEW.actor_user_id,
+             NEW.action, NEW.entity, NEW.entity_id, NEW.trace_id)
+            IS NOT DISTINCT FROM
+            (OLD.id, OLD.created_at, OLD.organization_id, OLD.actor_api_key_id, OLD.actor_user_id,
+             OLD.action, OLD.entity, OLD.entity_id, OLD.trace_id)
+    THEN
+        RETURN NEW;
+    END IF;
+
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+COMMIT;
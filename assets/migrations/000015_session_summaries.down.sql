@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS sessions_sess_end_idx;
+
+DROP TABLE IF EXISTS session_summaries;
+
+COMMIT;
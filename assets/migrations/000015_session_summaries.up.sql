@@ -0,0 +1,27 @@
+BEGIN;
+
+-- Sessions older than the retention period are archived into monthly totals per user and task.
+CREATE TABLE IF NOT EXISTS session_summaries (
+    id SERIAL PRIMARY KEY,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    organization_id INTEGER NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    user_id         INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    task_id         INTEGER NOT NULL,
+
+    -- First day of the month in UTC.
+    month DATE NOT NULL,
+
+    sessions_count INTEGER NOT NULL CHECK (sessions_count >= 0),
+    duration_ms    BIGINT  NOT NULL CHECK (duration_ms >= 0),
+
+    CONSTRAINT unique_session_summary UNIQUE (organization_id, user_id, task_id, month)
+);
+
+CREATE INDEX IF NOT EXISTS session_summaries_user_id_month_idx ON session_summaries (user_id, month);
+
+CREATE INDEX IF NOT EXISTS sessions_sess_end_idx ON sessions (sess_end);
+
+COMMIT;
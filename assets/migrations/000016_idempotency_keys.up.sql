@@ -0,0 +1,28 @@
+BEGIN;
+
+-- Responses of POST requests sent with the Idempotency-Key header, replayed for retries until they expire.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    organization_id INTEGER NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+
+    -- Caller of the request, e.g. apikey:1 or user:2.
+    scope TEXT NOT NULL,
+    key   TEXT NOT NULL CHECK (key <> ''),
+
+    -- SHA-256 of the method, URL and body of the request.
+    request_hash TEXT NOT NULL,
+
+    -- NULL while the first request is in progress.
+    status_code      INTEGER,
+    response_headers JSONB,
+    response_body    BYTEA,
+
+    expires_at TIMESTAMPTZ NOT NULL,
+
+    PRIMARY KEY (organization_id, scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS sessions_increment_version ON sessions;
+DROP TRIGGER IF EXISTS users_increment_version ON users;
+
+DROP FUNCTION IF EXISTS increment_row_version();
+
+ALTER TABLE sessions DROP COLUMN IF EXISTS version;
+ALTER TABLE users DROP COLUMN IF EXISTS version;
+
+COMMIT;
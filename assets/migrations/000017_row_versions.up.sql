@@ -0,0 +1,22 @@
+BEGIN;
+
+-- Version of the row is incremented on every update, it is used for ETags and optimistic concurrency.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION increment_row_version() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_increment_version
+    BEFORE UPDATE ON users
+    FOR EACH ROW EXECUTE FUNCTION increment_row_version();
+
+CREATE TRIGGER sessions_increment_version
+    BEFORE UPDATE ON sessions
+    FOR EACH ROW EXECUTE FUNCTION increment_row_version();
+
+COMMIT;
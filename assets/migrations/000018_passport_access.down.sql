@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS can_view_passports;
+ALTER TABLE users DROP COLUMN IF EXISTS can_view_passports;
+
+COMMIT;
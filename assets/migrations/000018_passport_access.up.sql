@@ -0,0 +1,11 @@
+BEGIN;
+
+-- Unmasked passports are visible only with explicit permission, not by role.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS can_view_passports BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS can_view_passports BOOLEAN NOT NULL DEFAULT false;
+
+-- Existing admins and service keys keep the access they had before.
+UPDATE users SET can_view_passports = true WHERE role = 'admin' AND erased_at IS NULL;
+UPDATE api_keys SET can_view_passports = true WHERE user_id IS NULL;
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+-- Encrypted responses can not be replayed without the key ID, they are dropped.
+DELETE FROM idempotency_keys WHERE status_code IS NOT NULL;
+
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS user_ids;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS response_key_id;
+
+COMMIT;
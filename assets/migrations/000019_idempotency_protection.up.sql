@@ -0,0 +1,11 @@
+BEGIN;
+
+-- Stored responses contain personal data, they are encrypted with the passport key (see pii.Protector),
+-- response_key_id is the ID of the key. Users referred to by the response are kept to purge it on erasure.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS response_key_id INTEGER;
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS user_ids INTEGER[] NOT NULL DEFAULT '{}';
+
+-- Responses stored in plaintext can not be encrypted here, they are short-lived and dropped instead.
+DELETE FROM idempotency_keys WHERE status_code IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/protomem/time-tracker/internal/auth"
+	"github.com/protomem/time-tracker/internal/ctxstore"
+	"github.com/protomem/time-tracker/internal/database"
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+// principal is the authenticated caller of the request,
+// authenticated either by API key or by access token of a login session.
+type principal struct {
+	APIKeyID       *model.ID
+	SessionID      *model.ID
+	UserID         *model.ID
+	OrganizationID model.ID
+	Role           model.Role
+	// CanViewPassports is granted explicitly to the user or API key, see canViewPassport.
+	CanViewPassports bool
+}
+
+// servicePrincipal is used when the key is not bound to a user or authentication is disabled.
+func servicePrincipal(apiKeyID *model.ID, organizationID model.ID, canViewPassports bool) principal {
+	return principal{
+		APIKeyID:         apiKeyID,
+		OrganizationID:   organizationID,
+		Role:             model.RoleAdmin,
+		CanViewPassports: canViewPassports,
+	}
+}
+
+// auditActor is recorded in the audit log for changes made by the principal.
+func (p principal) auditActor(r *http.Request) database.Actor {
+	traceID, _ := ctxstore.From[string](r.Context(), _traceIDKey)
+	return database.Actor{APIKey: p.APIKeyID, User: p.UserID, TraceID: traceID}
+}
+
+func principalFromRequest(r *http.Request) (principal, bool) {
+	return ctxstore.From[principal](r.Context(), _principalKey)
+}
+
+func authenticateAPIKey(ctx context.Context, db *database.DB, logger *slog.Logger, token string) (principal, error) {
+	if !auth.IsAPIKey(token) {
+		return principal{}, model.NewError("API key", model.ErrNotFound)
+	}
+
+	dao := database.NewAPIKeyDAO(logger, db)
+
+	key, err := dao.GetActiveByHash(ctx, auth.HashToken(token))
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return principal{}, model.NewError("API key", model.ErrNotFound)
+		}
+
+		return principal{}, err
+	}
+
+	if key.User == nil {
+		return servicePrincipal(&key.ID, key.Organization, key.CanViewPassports), nil
+	}
+
+	ctx = database.WithTenant(ctx, key.Organization)
+
+	user, err := database.NewUserDAO(logger, db).Get(ctx, *key.User)
+	if err != nil {
+		return principal{}, err
+	}
+
+	return principal{
+		APIKeyID:         &key.ID,
+		UserID:           &user.ID,
+		OrganizationID:   user.Organization,
+		Role:             user.Role,
+		CanViewPassports: key.CanViewPassports && user.CanViewPassports,
+	}, nil
+}
+
+func authenticateAccessToken(
+	ctx context.Context, db *database.DB, logger *slog.Logger, tokens *auth.TokenIssuer,
+	token string,
+) (principal, error) {
+	claims, err := tokens.Parse(token)
+	if err != nil {
+		logger.Debug("invalid access token", "error", err)
+		return principal{}, model.NewError("access token", model.ErrNotFound)
+	}
+
+	session, err := database.NewAuthSessionDAO(logger, db).Get(ctx, claims.SessionID)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return principal{}, model.NewError("access token", model.ErrNotFound)
+		}
+
+		return principal{}, err
+	}
+
+	if !session.Active(time.Now()) || session.User != claims.UserID {
+		return principal{}, model.NewError("access token", model.ErrNotFound)
+	}
+
+	ctx = database.WithTenant(ctx, session.Organization)
+
+	user, err := database.NewUserDAO(logger, db).Get(ctx, session.User)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return principal{}, model.NewError("access token", model.ErrNotFound)
+		}
+
+		return principal{}, err
+	}
+
+	return principal{
+		SessionID:        &session.ID,
+		UserID:           &user.ID,
+		OrganizationID:   user.Organization,
+		Role:             user.Role,
+		CanViewPassports: user.CanViewPassports,
+	}, nil
+}
@@ -1,17 +1,60 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/protomem/time-tracker/internal/ctxstore"
+	"github.com/protomem/time-tracker/internal/model"
 	"github.com/protomem/time-tracker/internal/response"
 	"github.com/protomem/time-tracker/internal/validator"
 )
 
+// Codes of errors which are not bound to a domain entity.
+const (
+	_codeBadRequest             = "bad_request"
+	_codeValidationFailed       = "validation_failed"
+	_codeAuthenticationRequired = "authentication_required"
+	_codeInvalidToken           = "invalid_token"
+	_codeInvalidCredentials     = "invalid_credentials"
+	_codeForbidden              = "forbidden"
+	_codePrincipalNotBound      = "principal_not_bound"
+	_codeNotFound               = "not_found"
+	_codeMethodNotAllowed       = "method_not_allowed"
+	_codeConflict               = "conflict"
+	_codePreconditionFailed     = "precondition_failed"
+	_codeRateLimitExceeded      = "rate_limit_exceeded"
+	_codeIdempotencyKeyReused   = "idempotency_key_reused"
+	_codeIdempotencyInProgress  = "idempotent_request_in_progress"
+	_codeInternalError          = "internal_error"
+)
+
+const _problemTypePrefix = "urn:time-tracker:problem:"
+
+// problem is an error response in the RFC 7807 format
+// extended with a stable code, the request ID and validation errors.
+type problem struct {
+	Type      string         `json:"type"`
+	Title     string         `json:"title"`
+	Status    int            `json:"status"`
+	Detail    string         `json:"detail,omitempty"`
+	Instance  string         `json:"instance,omitempty"`
+	Code      string         `json:"code"`
+	RequestID string         `json:"requestId,omitempty"`
+	Errors    []problemError `json:"errors,omitempty"`
+} //	@name	Problem
+
+type problemError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+} //	@name	ProblemError
+
 func (app *application) reportServerError(r *http.Request, err error) {
 	var (
 		message = err.Error()
@@ -19,22 +62,46 @@ func (app *application) reportServerError(r *http.Request, err error) {
 		url     = r.URL.String()
 		trace   = string(debug.Stack())
 		tid     = ctxstore.MustFrom[string](r.Context(), _traceIDKey)
+		rid, _  = ctxstore.From[string](r.Context(), _requestIDKey)
 	)
 
-	requestAttrs := slog.Group("request", "method", method, "url", url, _traceIDKey.String(), tid)
+	requestAttrs := slog.Group("request", "method", method, "url", url, _traceIDKey.String(), tid, _requestIDKey.String(), rid)
 	app.baseLogger.Error(message, requestAttrs, "trace", trace)
 }
 
-func (app *application) errorMessage(w http.ResponseWriter, r *http.Request, status int, message string, headers http.Header) {
-	message = strings.ToUpper(message[:1]) + message[1:]
+func (app *application) problem(w http.ResponseWriter, r *http.Request, p problem, headers http.Header) {
+	p.Type = _problemTypePrefix + p.Code
+	p.Title = http.StatusText(p.Status)
+	p.Instance = r.URL.Path
+	p.RequestID, _ = ctxstore.From[string](r.Context(), _requestIDKey)
 
-	err := response.JSONWithHeaders(w, status, map[string]string{"error": message}, headers)
+	if p.Detail != "" {
+		p.Detail = strings.ToUpper(p.Detail[:1]) + p.Detail[1:]
+	}
+
+	err := response.ProblemWithHeaders(w, p.Status, p, headers)
 	if err != nil {
 		app.reportServerError(r, err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
 
+func (app *application) errorMessage(w http.ResponseWriter, r *http.Request, status int, message string, headers http.Header) {
+	app.problem(w, r, problem{Status: status, Code: statusCode(status), Detail: message}, headers)
+}
+
+// domainError responds with the code of model.Error if err is one.
+func (app *application) domainError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	code := statusCode(status)
+
+	var domainErr *model.Error
+	if errors.As(err, &domainErr) {
+		code = domainErr.Code
+	}
+
+	app.problem(w, r, problem{Status: status, Code: code, Detail: err.Error()}, nil)
+}
+
 func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
 	app.reportServerError(r, err)
 
@@ -56,9 +123,103 @@ func (app *application) badRequest(w http.ResponseWriter, r *http.Request, err e
 	app.errorMessage(w, r, http.StatusBadRequest, err.Error(), nil)
 }
 
+func (app *application) authenticationRequired(w http.ResponseWriter, r *http.Request) {
+	headers := http.Header{"WWW-Authenticate": []string{"Bearer"}}
+	message := "You must be authenticated to access this resource"
+	app.problem(w, r, problem{Status: http.StatusUnauthorized, Code: _codeAuthenticationRequired, Detail: message}, headers)
+}
+
+func (app *application) invalidAuthenticationToken(w http.ResponseWriter, r *http.Request) {
+	headers := http.Header{"WWW-Authenticate": []string{`Bearer error="invalid_token"`}}
+	message := "Invalid or revoked authentication token"
+	app.problem(w, r, problem{Status: http.StatusUnauthorized, Code: _codeInvalidToken, Detail: message}, headers)
+}
+
+func (app *application) invalidCredentials(w http.ResponseWriter, r *http.Request) {
+	message := "Invalid or expired login code"
+	app.problem(w, r, problem{Status: http.StatusUnauthorized, Code: _codeInvalidCredentials, Detail: message}, nil)
+}
+
+func (app *application) forbidden(w http.ResponseWriter, r *http.Request) {
+	message := "You do not have permission to access this resource"
+	app.errorMessage(w, r, http.StatusForbidden, message, nil)
+}
+
+func (app *application) principalNotBound(w http.ResponseWriter, r *http.Request) {
+	message := "Authenticated principal is not bound to a user"
+	app.problem(w, r, problem{Status: http.StatusForbidden, Code: _codePrincipalNotBound, Detail: message}, nil)
+}
+
+func (app *application) preconditionFailed(w http.ResponseWriter, r *http.Request) {
+	message := "The resource was changed, fetch it again and retry with its current ETag"
+	app.errorMessage(w, r, http.StatusPreconditionFailed, message, nil)
+}
+
+func (app *application) invalidIdentityResponse(w http.ResponseWriter, r *http.Request) {
+	message := "The identity provider returned incomplete personal data"
+	app.errorMessage(w, r, http.StatusBadGateway, message, nil)
+}
+
+func (app *application) rateLimitExceeded(w http.ResponseWriter, r *http.Request, retryAfter int) {
+	headers := http.Header{_retryAfterHeader: []string{strconv.Itoa(max(retryAfter, 1))}}
+	message := "Too many requests, retry later"
+	app.problem(w, r, problem{Status: http.StatusTooManyRequests, Code: _codeRateLimitExceeded, Detail: message}, headers)
+}
+
+func (app *application) idempotencyKeyReused(w http.ResponseWriter, r *http.Request) {
+	message := "The idempotency key was already used for a different request"
+	app.problem(w, r, problem{Status: http.StatusUnprocessableEntity, Code: _codeIdempotencyKeyReused, Detail: message}, nil)
+}
+
+func (app *application) idempotentRequestInProgress(w http.ResponseWriter, r *http.Request) {
+	message := "A request with the same idempotency key is still in progress"
+	app.problem(w, r, problem{Status: http.StatusConflict, Code: _codeIdempotencyInProgress, Detail: message}, nil)
+}
+
 func (app *application) failedValidation(w http.ResponseWriter, r *http.Request, v *validator.Validator) {
-	err := response.JSON(w, http.StatusUnprocessableEntity, v)
-	if err != nil {
-		app.serverError(w, r, err)
+	errs := make([]problemError, 0, len(v.Errors)+len(v.FieldErrors))
+	for _, message := range v.Errors {
+		errs = append(errs, problemError{Message: message})
+	}
+
+	fields := make([]string, 0, len(v.FieldErrors))
+	for field := range v.FieldErrors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		errs = append(errs, problemError{Field: field, Message: v.FieldErrors[field]})
+	}
+
+	message := "The request contains invalid data"
+	app.problem(w, r, problem{Status: http.StatusUnprocessableEntity, Code: _codeValidationFailed, Detail: message, Errors: errs}, nil)
+}
+
+// statusCode is the generic code of an error without a more specific one.
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return _codeBadRequest
+	case http.StatusUnauthorized:
+		return _codeAuthenticationRequired
+	case http.StatusForbidden:
+		return _codeForbidden
+	case http.StatusNotFound:
+		return _codeNotFound
+	case http.StatusMethodNotAllowed:
+		return _codeMethodNotAllowed
+	case http.StatusConflict:
+		return _codeConflict
+	case http.StatusPreconditionFailed:
+		return _codePreconditionFailed
+	case http.StatusUnprocessableEntity:
+		return _codeValidationFailed
+	case http.StatusTooManyRequests:
+		return _codeRateLimitExceeded
+	case http.StatusInternalServerError:
+		return _codeInternalError
+	default:
+		return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
 	}
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+const (
+	_etagHeader        = "ETag"
+	_ifMatchHeader     = "If-Match"
+	_ifNoneMatchHeader = "If-None-Match"
+
+	_maskedETagSuffix = "-masked"
+)
+
+// versionETag is a strong ETag of the entity version,
+// the representation with masked personal data has its own tag.
+func versionETag(version int64, masked bool) string {
+	if masked {
+		return fmt.Sprintf(`"%d%s"`, version, _maskedETagSuffix)
+	}
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// userETag is the tag of the user representation returned by presentUser.
+func userETag(r *http.Request, user model.User) string {
+	p, ok := principalFromRequest(r)
+	return versionETag(user.Version, !ok || !canViewPassport(user.ID)(p))
+}
+
+// sessionsETag changes when any session of the list is added, changed or removed.
+func sessionsETag(sessions []model.Session) string {
+	hash := sha256.New()
+	for _, session := range sessions {
+		fmt.Fprintf(hash, "%d:%d;", session.ID, session.Version)
+	}
+
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// notModified sets the ETag of the response and writes 304 if it matches If-None-Match of the request.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set(_etagHeader, etag)
+
+	header := strings.Join(r.Header.Values(_ifNoneMatchHeader), ",")
+	if header == "" {
+		return false
+	}
+
+	matched := strings.TrimSpace(header) == "*"
+	for _, tag := range splitETags(header) {
+		// If-None-Match uses the weak comparison.
+		if strings.TrimPrefix(tag, "W/") == etag {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// ifMatchVersions parses versions of the If-Match header, nil versions allow any version.
+// It reports false if the header has no tag issued by the service, such a request never matches.
+func ifMatchVersions(r *http.Request) ([]int64, bool) {
+	header := strings.Join(r.Header.Values(_ifMatchHeader), ",")
+	if header == "" || strings.TrimSpace(header) == "*" {
+		return nil, true
+	}
+
+	versions := []int64{}
+	for _, tag := range splitETags(header) {
+		// If-Match uses the strong comparison, weak tags never match.
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+
+		value := strings.TrimSuffix(strings.Trim(tag, `"`), _maskedETagSuffix)
+		if version, err := strconv.ParseInt(value, 10, 64); err == nil {
+			versions = append(versions, version)
+		}
+	}
+
+	return versions, len(versions) != 0
+}
+
+func splitETags(header string) []string {
+	tags := strings.Split(header, ",")
+	for i := range tags {
+		tags[i] = strings.TrimSpace(tags[i])
+	}
+	return tags
+}
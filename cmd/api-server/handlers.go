@@ -8,12 +8,12 @@ import (
 	"log/slog"
 	"net/http"
 	"slices"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/protomem/time-tracker/internal/database"
-	"github.com/protomem/time-tracker/internal/external_api/people_service"
+	"github.com/protomem/time-tracker/internal/identity"
 	"github.com/protomem/time-tracker/internal/model"
 	"github.com/protomem/time-tracker/internal/request"
 	"github.com/protomem/time-tracker/internal/response"
@@ -39,7 +39,7 @@ func (app *application) handleStatus(w http.ResponseWriter, r *http.Request) {
 // Handle Find Users
 //
 //	@Summary		Find Users
-//	@Description	Get all users by filters with pagination
+//	@Description	Get all users by filters with pagination, managers get only members of the teams they manage
 //	@Tags			users
 //	@Produce		json
 //	@Param			page			query		int		false	"Page number"	default(1)	minimum(1)
@@ -48,17 +48,24 @@ func (app *application) handleStatus(w http.ResponseWriter, r *http.Request) {
 //	@Param			surname			query		string	false	"User surname"
 //	@Param			patronymic		query		string	false	"User patronymic"
 //	@Param			address			query		string	false	"User address"
-//	@Param			passportSerie	query		int		false	"User passport serie"
-//	@Param			passportNumber	query		int		false	"User passport number"
+//	@Param			passportSerie	query		string	false	"User passport serie, 4 digits"
+//	@Param			passportNumber	query		string	false	"User passport number, 6 digits"
 //	@Success		200				{array}		model.User
-//	@Failure		400				{object}	any					"Bad request"
-//	@Failure		422				{object}	validator.Validator	"Invalid input data"
-//	@Failure		500				{object}	any					"Internal server error"
+//	@Failure		400				{object}	problem	"Bad request"
+//	@Failure		401				{object}	problem	"Unauthorized"
+//	@Failure		403				{object}	problem	"Forbidden"
+//	@Failure		422				{object}	problem	"Invalid input data"
+//	@Failure		500				{object}	problem	"Internal server error"
+//	@Security		BearerAuth
 //	@Router			/users [get]
 func (app *application) handleFindUsers(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "findUsers")
 
+	if !app.authorize(w, r, canListUsers) {
+		return
+	}
+
 	opts := findOptionsFromRequest(r)
 	filter := findUserFilterFromRequest(r)
 
@@ -69,6 +76,10 @@ func (app *application) handleFindUsers(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if p, _ := principalFromRequest(r); !p.isAdmin() {
+		filter.ManagedBy = p.UserID
+	}
+
 	handlerLogger.Debug("read params and body", "filter", filter, "opts", opts)
 
 	users, err := findUsers(ctx, app.db, baseLogger, filter, opts)
@@ -79,6 +90,10 @@ func (app *application) handleFindUsers(w http.ResponseWriter, r *http.Request)
 
 	handlerLogger.Debug("users found", "count", len(users))
 
+	for i := range users {
+		users[i] = presentUser(r, users[i])
+	}
+
 	if err := response.JSON(w, http.StatusOK, users); err != nil {
 		app.serverError(w, r, err)
 	}
@@ -98,24 +113,113 @@ func findUsers(
 	return users, nil
 }
 
+// Handle Get User
+//
+//	@Summary		Get User
+//	@Description	Get user by ID, can be used to poll enrichment status
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path		int	true	"User ID"
+//	@Param			If-None-Match	header	string	false	"ETag of the cached representation"
+//	@Success		200		{object}	model.User
+//	@Header			200	{string}	ETag	"Tag of the representation"
+//	@Success		304	"Not modified"
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Unauthorized"
+//	@Failure		403		{object}	problem	"Forbidden"
+//	@Failure		404		{object}	problem	"User not found"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/users/{userId} [get]
+func (app *application) handleGetUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	managed, err := app.managesUser(r, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !app.authorize(w, r, canViewUser(userID, managed)) {
+		return
+	}
+
+	app.respondUser(w, r, userID)
+}
+
+// respondUser writes the user profile, the caller must be authorized to view the user.
+func (app *application) respondUser(w http.ResponseWriter, r *http.Request, userID model.ID) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "getUser")
+
+	handlerLogger.Debug("read params and body", "userId", userID)
+
+	user, err := getUser(ctx, app.db, baseLogger, userID)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	if notModified(w, r, userETag(r, user)) {
+		return
+	}
+
+	if err := response.JSON(w, http.StatusOK, presentUser(r, user)); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func getUser(ctx context.Context, db *database.DB, logger *slog.Logger, userID model.ID) (model.User, error) {
+	dao := database.NewUserDAO(logger, db)
+
+	user, err := dao.Get(ctx, userID)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
 // Handle Add User
 //
 //	@Summary		Add User
-//	@Description	Add new user
+//	@Description	Add new user. In async mode the user is stored with pending enrichment status
+//	@Description	and personal data is fetched from the people service in background.
+//	@Description	With manual identity provider personal data must be passed in the body.
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
-//	@Param			input	body		main.requestAddUser	true	"Passport serie and number"
+//	@Param			async	query		bool				false	"Enrich user data asynchronously"	default(false)
+//	@Param			input	body		main.requestAddUser	true	"Passport serie and number, personal data for manual identity provider"
+//	@Param			Idempotency-Key	header	string	false	"Key to safely retry the request"
 //	@Success		201		{object}	model.User
-//	@Failure		400		{object}	any					"Bad request input"
-//	@Failure		409		{object}	any					"User already exists"
-//	@Failure		422		{object}	validator.Validator	"Invalid input data"
-//	@Failure		500		{object}	any					"Internal server error"
+//	@Success		202		{object}	main.responseAcceptedUser
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Unauthorized"
+//	@Failure		403		{object}	problem	"Forbidden"
+//	@Failure		409		{object}	problem	"User already exists"
+//	@Failure		422		{object}	problem	"Invalid input data"
+//	@Failure		502		{object}	problem	"Incomplete data from identity provider"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Security		BearerAuth
 //	@Router			/users [post]
 func (app *application) handleAddUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "addUser")
 
+	if !app.authorize(w, r, canManageUsers) {
+		return
+	}
+
 	var input requestAddUser
 	if err := request.DecodeJSONStrict(w, r, &input); err != nil {
 		app.badRequest(w, r, err)
@@ -123,43 +227,93 @@ func (app *application) handleAddUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var (
-		err                           error
-		passportSerie, passportNumber int
+		err      error
+		passport model.Passport
 	)
 
+	manualMode := app.identity.Manual()
+
 	if v := validator.Validate(func(v *validator.Validator) {
-		passportSerie, passportNumber, err = parsePassportNumber(input.PassportNumber)
+		if manualMode {
+			validateRequestAddUserPerson(v, input)
+		} else {
+			validateRequestAddUserNoPerson(v, input)
+		}
+
+		passport, err = model.ParsePassport(input.PassportNumber)
 		if err != nil {
 			v.AddFieldError("passportNumber", err.Error())
 			return
 		}
 
-		validatePassportSerie(v, passportSerie)
-		validatePassportNumber(v, passportNumber)
+		validatePassport(v, passport)
 	}); v.HasErrors() {
 		app.failedValidation(w, r, v)
 		return
 	}
 
-	handlerLogger.Debug("read params and body", "passportSerie", passportSerie, "passportNumber", passportNumber)
+	asyncMode := defaultBoolQueryParams(r, "async", false)
 
-	people, err := fetchPeople(ctx, baseLogger, app.config.peopleServ.serverURL, passportSerie, passportNumber)
-	if err != nil {
-		if errors.Is(err, model.ErrNotFound) {
-			app.errorMessage(w, r, http.StatusNotFound, err.Error(), nil)
+	handlerLogger.Debug("read params and body", "passport", passport, "async", asyncMode)
+
+	if asyncMode {
+		if manualMode {
+			app.badRequest(w, r, errors.New("async mode is not supported by manual identity provider"))
 			return
 		}
 
-		app.serverError(w, r, err)
+		userID, err := insertPendingUser(ctx, app.db, baseLogger, passport)
+		if err != nil {
+			if errors.Is(err, model.ErrExists) {
+				app.domainError(w, r, http.StatusConflict, err)
+				return
+			}
+
+			app.serverError(w, r, err)
+			return
+		}
+
+		handlerLogger.Debug("inserted pending user", "userId", userID)
+
+		recordIdempotentUsers(r, userID)
+
+		app.enrichUserInBackground(ctx, baseLogger, userID, passport)
+
+		headers := http.Header{"Location": []string{fmt.Sprintf("/api/v1/users/%d", userID)}}
+		if err := response.JSONWithHeaders(w, http.StatusAccepted, responseAcceptedUser{
+			ID:               userID,
+			EnrichmentStatus: model.EnrichmentPending,
+		}, headers); err != nil {
+			app.serverError(w, r, err)
+		}
+
 		return
 	}
 
-	// TODO: Validate people ?
+	var person identity.Person
+	if manualMode {
+		person = input.person()
+	} else {
+		person, err = lookupPerson(ctx, app.identity, baseLogger, passport)
+		if err != nil {
+			if errors.Is(err, model.ErrNotFound) {
+				app.domainError(w, r, http.StatusNotFound, err)
+				return
+			}
+			if errors.Is(err, identity.ErrInvalidPerson) {
+				app.invalidIdentityResponse(w, r)
+				return
+			}
 
-	user, err := insertUser(ctx, app.db, baseLogger, people, passportSerie, passportNumber)
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	user, err := insertUser(ctx, app.db, baseLogger, person, passport)
 	if err != nil {
 		if errors.Is(err, model.ErrExists) {
-			app.errorMessage(w, r, http.StatusConflict, err.Error(), nil)
+			app.domainError(w, r, http.StatusConflict, err)
 			return
 		}
 
@@ -169,76 +323,62 @@ func (app *application) handleAddUser(w http.ResponseWriter, r *http.Request) {
 
 	handlerLogger.Debug("inserted user", "userId", user.ID)
 
-	if err := response.JSON(w, http.StatusCreated, user); err != nil {
+	recordIdempotentUsers(r, user.ID)
+
+	if err := response.JSON(w, http.StatusCreated, presentUser(r, user)); err != nil {
 		app.serverError(w, r, err)
 	}
 }
 
 type requestAddUser struct {
 	PassportNumber string `json:"passportNumber"`
-}
 
-func parsePassportNumber(s string) (passportSerie int, passportNumber int, err error) {
-	parts := strings.Split(s, " ")
-	if len(parts) != 2 {
-		return 0, 0, errors.New("invalid passport data")
-	}
+	// Personal data is accepted only with manual identity provider.
+	Name       *string `json:"name,omitempty"`
+	Surname    *string `json:"surname,omitempty"`
+	Patronymic *string `json:"patronymic,omitempty"`
+	Address    *string `json:"address,omitempty"`
+}
 
-	if passportSerie, err = strconv.Atoi(parts[0]); err != nil {
-		return 0, 0, fmt.Errorf("invalid passport serie: not a number")
-	}
-	if passportNumber, err = strconv.Atoi(parts[1]); err != nil {
-		return 0, 0, fmt.Errorf("invalid passport number: not a number")
+func (input requestAddUser) person() identity.Person {
+	return identity.Person{
+		Name:       lo.FromPtr(input.Name),
+		Surname:    lo.FromPtr(input.Surname),
+		Patronymic: input.Patronymic,
+		Address:    lo.FromPtr(input.Address),
 	}
+}
 
-	return
+type responseAcceptedUser struct {
+	ID               model.ID               `json:"id"`
+	EnrichmentStatus model.EnrichmentStatus `json:"enrichmentStatus"`
 }
 
-func fetchPeople(
-	ctx context.Context, logger *slog.Logger, addr string,
-	passportSerie int, passportNumber int,
-) (*people_service.People, error) {
-	logger.Debug("connect to people service", "addr", addr)
+func lookupPerson(
+	ctx context.Context, provider identity.Provider, logger *slog.Logger,
+	passport model.Passport,
+) (identity.Person, error) {
+	logger.Debug("lookup person", "passport", passport)
 
-	client, err := people_service.NewClient(addr)
+	person, err := provider.Lookup(ctx, identity.Document{Passport: passport})
 	if err != nil {
-		return nil, err
+		return identity.Person{}, err
 	}
 
-	logger.Debug("do people request", "passportSerie", passportSerie, "passportNumber", passportNumber)
-
-	infoPeopleReq, err := client.InfoGet(ctx, people_service.InfoGetParams{
-		PassportSerie:  passportSerie,
-		PassportNumber: passportNumber,
-	})
-	if err != nil {
-		return nil, err
+	if err := person.Validate(); err != nil {
+		return identity.Person{}, err
 	}
 
-	switch infoPeopleReq := infoPeopleReq.(type) {
-	case (*people_service.People):
-		logger.Debug("fetch people", "people", infoPeopleReq)
-		return infoPeopleReq, nil
-	default:
-		logger.Warn("unknown people response", "people", fmt.Sprintf("%T", infoPeopleReq))
-		return nil, model.NewError("user", model.ErrNotFound)
-	}
+	return person, nil
 }
 
 func insertUser(
 	ctx context.Context, db *database.DB, logger *slog.Logger,
-	people *people_service.People, passportSerie int, passportNumber int,
+	person identity.Person, passport model.Passport,
 ) (model.User, error) {
 	dao := database.NewUserDAO(logger, db)
 
-	insertDTO := database.NewInsertUserDTO(
-		people.GetName(), people.GetSurname(),
-		passportSerie, passportNumber,
-		people.GetAddress(),
-	)
-	if people.GetPatronymic().Set {
-		insertDTO.SetPatronymic(people.GetPatronymic().Value)
-	}
+	insertDTO := newInsertUserDTOFromPerson(person, passport)
 
 	userID, err := dao.Insert(ctx, insertDTO)
 	if err != nil {
@@ -257,52 +397,470 @@ func insertUser(
 	return user, nil
 }
 
+func newInsertUserDTOFromPerson(person identity.Person, passport model.Passport) database.InsertUserDTO {
+	insertDTO := database.NewInsertUserDTO(
+		person.Name, person.Surname,
+		passport,
+		person.Address,
+	)
+	if person.Patronymic != nil {
+		insertDTO.SetPatronymic(*person.Patronymic)
+	}
+	return insertDTO
+}
+
+func insertPendingUser(
+	ctx context.Context, db *database.DB, logger *slog.Logger,
+	passport model.Passport,
+) (model.ID, error) {
+	dao := database.NewUserDAO(logger, db)
+
+	userID, err := dao.Insert(ctx, database.NewPendingInsertUserDTO(passport))
+	if err != nil {
+		if errors.Is(err, model.ErrExists) {
+			return 0, model.NewError("user", model.ErrExists)
+		}
+
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// Handle Import Users
+//
+//	@Summary		Import Users
+//	@Description	Bulk add users by passport list. Body is a JSON array of passport strings
+//	@Description	or CSV with passport in the first column, in the same format as for adding a user.
+//	@Tags			users
+//	@Accept			json,text/csv
+//	@Produce		json
+//	@Param			input	body		[]string	true	"Passport serie and number list"
+//	@Success		200		{object}	main.responseImportUsers
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Unauthorized"
+//	@Failure		403		{object}	problem	"Forbidden"
+//	@Failure		422		{object}	problem	"Invalid input data"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/users/import [post]
+func (app *application) handleImportUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "importUsers")
+
+	if !app.authorize(w, r, canManageUsers) {
+		return
+	}
+
+	passports, err := importPassportsFromRequest(w, r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if v := validator.Validate(func(v *validator.Validator) {
+		v.CheckField(len(passports) != 0, "passports", "must contain at least one passport")
+		v.CheckField(len(passports) <= _importMaxRows, "passports", fmt.Sprintf("must contain at most %d passports", _importMaxRows))
+	}); v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	if app.identity.Manual() {
+		app.badRequest(w, r, errors.New("import is not supported by manual identity provider"))
+		return
+	}
+
+	handlerLogger.Debug("read params and body", "countPassports", len(passports))
+
+	results, err := importUsers(ctx, app.db, baseLogger, app.identity, passports)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	summary := lo.CountValuesBy(results, func(result importUserResult) importUserStatus {
+		return result.Status
+	})
+
+	handlerLogger.Debug("users imported", "summary", summary)
+
+	for _, result := range results {
+		if result.UserID != nil {
+			recordIdempotentUsers(r, *result.UserID)
+		}
+	}
+
+	if err := response.JSON(w, http.StatusOK, responseImportUsers{Summary: summary, Results: results}); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+const (
+	_importMaxRows     = 1000
+	_importBatchSize   = 50
+	_importConcurrency = 8
+)
+
+type importUserStatus string
+
+const (
+	importUserCreated  importUserStatus = "created"
+	importUserExists   importUserStatus = "exists"
+	importUserNotFound importUserStatus = "not_found"
+	importUserInvalid  importUserStatus = "invalid"
+	importUserFailed   importUserStatus = "failed"
+)
+
+type importUserResult struct {
+	Row      int              `json:"row"`
+	Passport string           `json:"passport"`
+	Status   importUserStatus `json:"status"`
+	UserID   *model.ID        `json:"userId,omitempty"`
+	Error    string           `json:"error,omitempty"`
+
+	passport model.Passport
+	person   *identity.Person
+}
+
+type responseImportUsers struct {
+	Summary map[importUserStatus]int `json:"summary"`
+	Results []importUserResult       `json:"results"`
+}
+
+func importPassportsFromRequest(w http.ResponseWriter, r *http.Request) ([]string, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		records, err := request.DecodeCSV(w, r)
+		if err != nil {
+			return []string{}, err
+		}
+
+		return lo.FilterMap(records, func(record []string, _ int) (string, bool) {
+			passport := strings.TrimSpace(record[0])
+			return passport, passport != ""
+		}), nil
+	}
+
+	var passports []string
+	if err := request.DecodeJSON(w, r, &passports); err != nil {
+		return []string{}, err
+	}
+
+	return passports, nil
+}
+
+// importUsers fetches people data for every valid passport with bounded concurrency
+// and inserts found users in a transaction per batch.
+func importUsers(
+	ctx context.Context, db *database.DB, logger *slog.Logger, provider identity.Provider,
+	passports []string,
+) ([]importUserResult, error) {
+	dao := database.NewUserDAO(logger, db)
+
+	results := make([]importUserResult, len(passports))
+	pending := make([]*importUserResult, 0, len(passports))
+
+	for i, passport := range passports {
+		result := &results[i]
+		result.Row = i + 1
+		result.Passport = passport
+
+		var err error
+		result.passport, err = model.ParsePassport(passport)
+		if err == nil {
+			if v := validator.Validate(func(v *validator.Validator) {
+				validatePassport(v, result.passport)
+			}); v.HasErrors() {
+				err = model.ErrInvalidPassport
+			}
+		}
+		if err != nil {
+			result.Status = importUserInvalid
+			result.Error = err.Error()
+			continue
+		}
+
+		pending = append(pending, result)
+	}
+
+	for _, batch := range lo.Chunk(pending, _importBatchSize) {
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, _importConcurrency)
+
+		for _, result := range batch {
+			wg.Add(1)
+			sem <- struct{}{}
+
+			go func(result *importUserResult) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				person, err := lookupPerson(ctx, provider, logger, result.passport)
+				switch {
+				case errors.Is(err, model.ErrNotFound):
+					result.Status = importUserNotFound
+				case errors.Is(err, identity.ErrInvalidPerson):
+					result.Status = importUserFailed
+					result.Error = "identity provider returned incomplete data"
+				case err != nil:
+					result.Status = importUserFailed
+					result.Error = "identity provider unavailable"
+					logger.Warn("failed to fetch people", "row", result.Row, "error", err)
+				default:
+					result.person = &person
+				}
+			}(result)
+		}
+
+		wg.Wait()
+
+		found := lo.Filter(batch, func(result *importUserResult, _ int) bool {
+			return result.person != nil
+		})
+		if len(found) == 0 {
+			continue
+		}
+
+		ids, err := dao.InsertBatch(ctx, lo.Map(found, func(result *importUserResult, _ int) database.InsertUserDTO {
+			return newInsertUserDTOFromPerson(*result.person, result.passport)
+		}))
+		if err != nil {
+			return []importUserResult{}, err
+		}
+
+		for i, result := range found {
+			if ids[i] == 0 {
+				result.Status = importUserExists
+				continue
+			}
+
+			result.Status = importUserCreated
+			result.UserID = lo.ToPtr(ids[i])
+		}
+	}
+
+	return results, nil
+}
+
+// Handle Refresh User
+//
+//	@Summary		Refresh User
+//	@Description	Re-synchronise user personal data from the people service
+//	@Tags			users
+//	@Produce		json
+//	@Param			userId	path		int	true	"User ID"
+//	@Success		200		{object}	main.responseRefreshUser
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Unauthorized"
+//	@Failure		403		{object}	problem	"Forbidden"
+//	@Failure		404		{object}	problem	"User not found"
+//	@Failure		409		{object}	problem	"User erased"
+//	@Failure		502		{object}	problem	"Incomplete data from identity provider"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/users/{userId}/refresh [post]
+func (app *application) handleRefreshUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "refreshUser")
+
+	if !app.authorize(w, r, canManageUsers) {
+		return
+	}
+
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	handlerLogger.Debug("read params and body", "userId", userID)
+
+	if app.identity.Manual() {
+		app.badRequest(w, r, errors.New("refresh is not supported by manual identity provider"))
+		return
+	}
+
+	user, err := getUser(ctx, app.db, baseLogger, userID)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	changes, err := syncUser(ctx, app.db, baseLogger, app.identity, user)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, model.ErrErased) {
+			app.domainError(w, r, http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, identity.ErrInvalidPerson) {
+			app.invalidIdentityResponse(w, r)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	user, err = getUser(ctx, app.db, baseLogger, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	handlerLogger.Debug("user refreshed", "userId", userID, "countChanges", len(changes))
+
+	if err := response.JSON(w, http.StatusOK, responseRefreshUser{User: presentUser(r, user), Changes: changes}); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+type responseRefreshUser struct {
+	User    model.User         `json:"user"`
+	Changes []model.UserChange `json:"changes"`
+}
+
 // Handle Update User
 //
 //	@Summary		Update user
-//	@Description	Update user
+//	@Description	Replace user data, all fields except patronymic are required, omitted patronymic is cleared
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
-//	@Param			userId	path		int						true	"User ID"
-//	@Param			input	body		main.requestUpdateUser	true	"New user data"
-//	@Success		200		{object}	model.User
-//	@Failure		400		{object}	any					"Bad request"
-//	@Failure		404		{object}	any					"User not found"
-//	@Failure		409		{object}	any					"User already exists"
-//	@Failure		422		{object}	validator.Validator	"Invalid input data"
-//	@Failure		500		{object}	any					"Internal server error"
+//	@Param			userId		path		int						true	"User ID"
+//	@Param			input		body		main.requestReplaceUser	true	"New user data"
+//	@Param			If-Match	header		string					false	"ETag of the user, the request fails if it has changed"
+//	@Success		200			{object}	model.User
+//	@Header			200			{string}	ETag	"Tag of the representation"
+//	@Failure		400			{object}	problem	"Bad request"
+//	@Failure		401			{object}	problem	"Unauthorized"
+//	@Failure		403			{object}	problem	"Forbidden"
+//	@Failure		404			{object}	problem	"User not found"
+//	@Failure		409			{object}	problem	"User already exists"
+//	@Failure		412			{object}	problem	"User has changed"
+//	@Failure		422			{object}	problem	"Invalid input data"
+//	@Failure		500			{object}	problem	"Internal server error"
+//	@Security		BearerAuth
 //	@Router			/users/{userId} [put]
 func (app *application) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "updateUser")
+	if !app.authorize(w, r, canManageUsers) {
+		return
+	}
 
-	userID, err := userIDFromRequest(r)
-	if err != nil {
+	var input requestReplaceUser
+	if err := request.DecodeJSONStrict(w, r, &input); err != nil {
 		app.badRequest(w, r, err)
 		return
 	}
 
-	var input requestUpdateUser
-	err = request.DecodeJSON(w, r, &input)
-	if err != nil {
+	input.normalizePassport()
+
+	if v := validator.Validate(func(v *validator.Validator) {
+		validateRequestReplaceUser(v, input)
+	}); v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	app.updateUser(w, r, input.updateDTO())
+}
+
+// Handle Patch User
+//
+//	@Summary		Patch user
+//	@Description	Update user fields present in the JSON merge patch, null clears patronymic
+//	@Tags			users
+//	@Accept			application/merge-patch+json
+//	@Produce		json
+//	@Param			userId		path		int					true	"User ID"
+//	@Param			input		body		main.requestPatchUser	true	"Changed user data"
+//	@Param			If-Match	header		string				false	"ETag of the user, the request fails if it has changed"
+//	@Success		200			{object}	model.User
+//	@Header			200			{string}	ETag	"Tag of the representation"
+//	@Failure		400			{object}	problem	"Bad request"
+//	@Failure		401			{object}	problem	"Unauthorized"
+//	@Failure		403			{object}	problem	"Forbidden"
+//	@Failure		404			{object}	problem	"User not found"
+//	@Failure		409			{object}	problem	"User already exists"
+//	@Failure		412			{object}	problem	"User has changed"
+//	@Failure		415			{object}	problem	"Unsupported content type"
+//	@Failure		422			{object}	problem	"Invalid input data"
+//	@Failure		500			{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/users/{userId} [patch]
+func (app *application) handlePatchUser(w http.ResponseWriter, r *http.Request) {
+	if !app.authorize(w, r, canManageUsers) {
+		return
+	}
+
+	var input requestPatchUser
+	if err := request.DecodeMergePatch(w, r, &input); err != nil {
+		if errors.Is(err, request.ErrUnsupportedMediaType) {
+			app.errorMessage(w, r, http.StatusUnsupportedMediaType, err.Error(), nil)
+			return
+		}
+
 		app.badRequest(w, r, err)
 		return
 	}
 
+	input.normalizePassport()
+
 	if v := validator.Validate(func(v *validator.Validator) {
-		validateRequestUpdateUser(v, input)
+		validateRequestPatchUser(v, input)
 	}); v.HasErrors() {
 		app.failedValidation(w, r, v)
 		return
 	}
 
-	handlerLogger.Debug("read params and body", "userId", userID, "input", input)
+	app.updateUser(w, r, input.updateDTO())
+}
+
+// updateUser applies the update of the user from the request path
+// on condition of If-Match and writes the updated user.
+func (app *application) updateUser(w http.ResponseWriter, r *http.Request, dto database.UpdateUserDTO) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "updateUser")
+
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	ifMatch, ok := ifMatchVersions(r)
+	if !ok {
+		app.preconditionFailed(w, r)
+		return
+	}
+	dto.IfMatch = ifMatch
 
-	user, err := updateUser(ctx, app.db, baseLogger, userID, input)
+	if dto.CanViewPassports != nil && !app.authorize(w, r, canGrantPassportAccess) {
+		return
+	}
+
+	handlerLogger.Debug("read params and body", "userId", userID, "ifMatch", dto.IfMatch, "canViewPassports", dto.CanViewPassports)
+
+	user, err := updateUser(ctx, app.db, baseLogger, userID, dto)
 	if err != nil {
 		if errors.Is(err, model.ErrNotFound) {
-			app.errorMessage(w, r, http.StatusNotFound, err.Error(), nil)
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, model.ErrExists) || errors.Is(err, model.ErrErased) {
+			app.domainError(w, r, http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, model.ErrVersionMismatch) {
+			app.domainError(w, r, http.StatusPreconditionFailed, err)
 			return
 		}
 
@@ -312,23 +870,80 @@ func (app *application) handleUpdateUser(w http.ResponseWriter, r *http.Request)
 
 	handlerLogger.Debug("user updated", "updatedUserId", user.ID)
 
-	if err := response.JSON(w, http.StatusOK, user); err != nil {
+	w.Header().Set(_etagHeader, userETag(r, user))
+
+	if err := response.JSON(w, http.StatusOK, presentUser(r, user)); err != nil {
 		app.serverError(w, r, err)
 	}
 }
 
-type requestUpdateUser struct {
-	Name           *string `json:"name"`
-	Surname        *string `json:"surname"`
-	Patronymic     *string `json:"patronymic"`
-	PassportSerie  *int    `json:"passportSerie"`
-	PassportNumber *int    `json:"passportNumber"`
-	Address        *string `json:"address"`
+// requestReplaceUser is the full representation of the user, omitted patronymic is cleared.
+// Omitted canViewPassports is not changed, granting it requires the permission.
+type requestReplaceUser struct {
+	Name             string     `json:"name"`
+	Surname          string     `json:"surname"`
+	Patronymic       *string    `json:"patronymic"`
+	PassportSerie    string     `json:"passportSerie"`
+	PassportNumber   string     `json:"passportNumber"`
+	Address          string     `json:"address"`
+	Role             model.Role `json:"role"`
+	CanViewPassports *bool      `json:"canViewPassports,omitempty"`
+}
+
+func (input *requestReplaceUser) normalizePassport() {
+	input.PassportSerie = model.NormalizePassportPart(input.PassportSerie)
+	input.PassportNumber = model.NormalizePassportPart(input.PassportNumber)
+}
+
+func (input requestReplaceUser) updateDTO() database.UpdateUserDTO {
+	return database.UpdateUserDTO{
+		Name:             &input.Name,
+		Surname:          &input.Surname,
+		Patronymic:       input.Patronymic,
+		ClearPatronymic:  input.Patronymic == nil,
+		PassportSerie:    &input.PassportSerie,
+		PassportNumber:   &input.PassportNumber,
+		Address:          &input.Address,
+		Role:             &input.Role,
+		CanViewPassports: input.CanViewPassports,
+	}
+}
+
+// requestPatchUser is a JSON merge patch of the user, absent fields are not changed.
+type requestPatchUser struct {
+	Name           request.Field[string]     `json:"name" swaggertype:"string"`
+	Surname        request.Field[string]     `json:"surname" swaggertype:"string"`
+	Patronymic     request.Field[string]     `json:"patronymic" swaggertype:"string" extensions:"x-nullable"`
+	PassportSerie  request.Field[string]     `json:"passportSerie" swaggertype:"string"`
+	PassportNumber request.Field[string]     `json:"passportNumber" swaggertype:"string"`
+	Address        request.Field[string]     `json:"address" swaggertype:"string"`
+	Role           request.Field[model.Role] `json:"role" swaggertype:"string"`
+	// CanViewPassports is granted only by a caller who has the permission.
+	CanViewPassports request.Field[bool] `json:"canViewPassports" swaggertype:"boolean"`
+}
+
+func (input *requestPatchUser) normalizePassport() {
+	input.PassportSerie.Value = model.NormalizePassportPart(input.PassportSerie.Value)
+	input.PassportNumber.Value = model.NormalizePassportPart(input.PassportNumber.Value)
+}
+
+func (input requestPatchUser) updateDTO() database.UpdateUserDTO {
+	return database.UpdateUserDTO{
+		Name:             input.Name.Ptr(),
+		Surname:          input.Surname.Ptr(),
+		Patronymic:       input.Patronymic.Ptr(),
+		ClearPatronymic:  input.Patronymic.Null,
+		PassportSerie:    input.PassportSerie.Ptr(),
+		PassportNumber:   input.PassportNumber.Ptr(),
+		Address:          input.Address.Ptr(),
+		Role:             input.Role.Ptr(),
+		CanViewPassports: input.CanViewPassports.Ptr(),
+	}
 }
 
 func updateUser(
 	ctx context.Context, db *database.DB, logger *slog.Logger,
-	userID model.ID, requestBody requestUpdateUser,
+	userID model.ID, dto database.UpdateUserDTO,
 ) (model.User, error) {
 	dao := database.NewUserDAO(logger, db)
 
@@ -342,8 +957,6 @@ func updateUser(
 		return model.User{}, err
 	}
 
-	dto := database.UpdateUserDTO(requestBody)
-
 	if err := dao.Update(ctx, userID, dto); err != nil {
 		return model.User{}, err
 	}
@@ -365,26 +978,45 @@ func updateUser(
 //	@Tags			users
 //	@Produce		json
 //	@Param			userId	path	int	true	"User ID"
+//	@Param			If-Match	header	string	false	"ETag of the user, the request fails if it has changed"
 //	@Success		204
-//	@Failure		400	{object}	any	"Bad request input"
-//	@Failure		404	{object}	any	"User not found"
-//	@Failure		500	{object}	any	"Internal server error"
+//	@Failure		400	{object}	problem	"Bad request input"
+//	@Failure		401	{object}	problem	"Unauthorized"
+//	@Failure		403	{object}	problem	"Forbidden"
+//	@Failure		404	{object}	problem	"User not found"
+//	@Failure		412	{object}	problem	"User has changed"
+//	@Failure		500	{object}	problem	"Internal server error"
+//	@Security		BearerAuth
 //	@Router			/users/{userId} [delete]
 func (app *application) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "deleteUser")
 
+	if !app.authorize(w, r, canManageUsers) {
+		return
+	}
+
 	userID, err := userIDFromRequest(r)
 	if err != nil {
 		app.badRequest(w, r, err)
 		return
 	}
 
+	ifMatch, ok := ifMatchVersions(r)
+	if !ok {
+		app.preconditionFailed(w, r)
+		return
+	}
+
 	handlerLogger.Debug("read params and body", "userId", userID)
 
-	if err := deleteUser(ctx, app.db, baseLogger, userID); err != nil {
+	if err := deleteUser(ctx, app.db, baseLogger, userID, ifMatch); err != nil {
 		if errors.Is(err, model.ErrNotFound) {
-			app.errorMessage(w, r, http.StatusNotFound, err.Error(), nil)
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, model.ErrVersionMismatch) {
+			app.domainError(w, r, http.StatusPreconditionFailed, err)
 			return
 		}
 
@@ -397,7 +1029,7 @@ func (app *application) handleDeleteUser(w http.ResponseWriter, r *http.Request)
 
 func deleteUser(
 	ctx context.Context, db *database.DB, logger *slog.Logger,
-	userID model.ID,
+	userID model.ID, ifMatch []int64,
 ) error {
 	dao := database.NewUserDAO(logger, db)
 
@@ -413,7 +1045,7 @@ func deleteUser(
 
 	logger.Debug("delete user", "userId", userID)
 
-	if err := dao.Delete(ctx, userID); err != nil {
+	if err := dao.Delete(ctx, userID, ifMatch); err != nil {
 		return err
 	}
 
@@ -427,26 +1059,47 @@ func deleteUser(
 //	@Tags			sessions
 //	@Produce		json
 //	@Param			userId	path		int	true	"User ID"
+//	@Param			If-None-Match	header	string	false	"ETag of the cached representation"
 //	@Success		200		{object}	[]model.Session
-//	@Failure		400		{object}	any	"Bad request input"
-//	@Failure		404		{object}	any	"User not found"
-//	@Failure		500		{object}	any	"Internal server error"
+//	@Header			200	{string}	ETag	"Tag of the representation"
+//	@Success		304	"Not modified"
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Unauthorized"
+//	@Failure		403		{object}	problem	"Forbidden"
+//	@Failure		404		{object}	problem	"User not found"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Security		BearerAuth
 //	@Router			/sessions/{userId} [get]
 func (app *application) handleFindSessions(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "findSessions")
-
 	userID, err := userIDFromRequest(r)
 	if err != nil {
 		app.badRequest(w, r, err)
 		return
 	}
 
+	managed, err := app.managesUser(r, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !app.authorize(w, r, canViewUser(userID, managed)) {
+		return
+	}
+
+	app.respondSessions(w, r, userID)
+}
+
+// respondSessions writes all sessions of the user, the caller must be authorized to view the user.
+func (app *application) respondSessions(w http.ResponseWriter, r *http.Request, userID model.ID) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "findSessions")
+
 	handlerLogger.Debug("read params and body", "userId", userID)
 
 	if err := checkUserExists(ctx, app.db, baseLogger, userID); err != nil {
 		if errors.Is(err, model.ErrNotFound) {
-			app.errorMessage(w, r, http.StatusNotFound, err.Error(), nil)
+			app.domainError(w, r, http.StatusNotFound, err)
 			return
 		}
 
@@ -462,6 +1115,10 @@ func (app *application) handleFindSessions(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if notModified(w, r, sessionsETag(sessions)) {
+		return
+	}
+
 	if err := response.JSON(w, http.StatusOK, sessions); err != nil {
 		app.serverError(w, r, err)
 	}
@@ -475,21 +1132,37 @@ func (app *application) handleFindSessions(w http.ResponseWriter, r *http.Reques
 //	@Produce		json
 //	@Param			userId	path	int	true	"User ID"
 //	@Param			taskId	path	int	true	"Task ID"
+//	@Param			Idempotency-Key	header	string	false	"Key to safely retry the request"
 //	@Success		201
-//	@Failure		400	{object}	any	"Bad request input"
-//	@Failure		409	{object}	any	"Session already exists"
-//	@Failure		500	{object}	any	"Internal server error"
+//	@Header			201	{string}	ETag	"Tag of the started session"
+//	@Failure		400	{object}	problem	"Bad request input"
+//	@Failure		401	{object}	problem	"Unauthorized"
+//	@Failure		403	{object}	problem	"Forbidden"
+//	@Failure		404	{object}	problem	"User not found"
+//	@Failure		409	{object}	problem	"Session already exists"
+//	@Failure		422	{object}	problem	"Idempotency key reused"
+//	@Failure		500	{object}	problem	"Internal server error"
+//	@Security		BearerAuth
 //	@Router			/sessions/{userId}/{taskId} [post]
 func (app *application) handleSessionStart(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "sessionStart")
-
 	userID, err := userIDFromRequest(r)
 	if err != nil {
 		app.badRequest(w, r, err)
 		return
 	}
 
+	if !app.authorize(w, r, canTrackSessions(userID)) {
+		return
+	}
+
+	app.startSession(w, r, userID)
+}
+
+// startSession starts the session of the user for the task from the request.
+func (app *application) startSession(w http.ResponseWriter, r *http.Request, userID model.ID) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "sessionStart")
+
 	taskID, err := taskIDFromRequest(r)
 	if err != nil {
 		app.badRequest(w, r, err)
@@ -498,9 +1171,24 @@ func (app *application) handleSessionStart(w http.ResponseWriter, r *http.Reques
 
 	handlerLogger.Debug("read params and body", "userId", userID, "taskId", taskID)
 
-	if _, err := insertSessionWithCheckExistsNotEnded(ctx, app.db, baseLogger, userID, taskID); err != nil {
+	if err := checkUserActive(ctx, app.db, baseLogger, userID); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, model.ErrErased) {
+			app.domainError(w, r, http.StatusConflict, err)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	session, err := insertSessionWithCheckExistsNotEnded(ctx, app.db, baseLogger, userID, taskID)
+	if err != nil {
 		if errors.Is(err, model.ErrExists) {
-			app.errorMessage(w, r, http.StatusConflict, err.Error(), nil)
+			app.domainError(w, r, http.StatusConflict, err)
 			return
 		}
 
@@ -508,6 +1196,8 @@ func (app *application) handleSessionStart(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// The tag allows to stop exactly this session with If-Match.
+	w.Header().Set(_etagHeader, versionETag(session.Version, false))
 	w.WriteHeader(http.StatusCreated)
 }
 
@@ -525,7 +1215,7 @@ func insertSessionWithCheckExistsNotEnded(
 	}
 
 	if !errors.Is(err, model.ErrNotFound) && session.End == nil {
-		return model.Session{}, model.NewError("session", model.ErrExists)
+		return model.Session{}, model.NewErrorWithCode("session", model.ErrExists, model.CodeSessionAlreadyRunning)
 	}
 
 	logger.Debug("insert session", "userId", userID, "taskId", taskID)
@@ -535,7 +1225,7 @@ func insertSessionWithCheckExistsNotEnded(
 	sessionID, err := dao.Insert(ctx, dto)
 	if err != nil {
 		if errors.Is(err, model.ErrExists) {
-			return model.Session{}, model.NewError("session", model.ErrExists)
+			return model.Session{}, model.NewErrorWithCode("session", model.ErrExists, model.CodeSessionAlreadyRunning)
 		}
 
 		return model.Session{}, err
@@ -563,32 +1253,56 @@ func insertSessionWithCheckExistsNotEnded(
 //	@Produce		json
 //	@Param			userId	path	int	true	"User ID"
 //	@Param			taskId	path	int	true	"Task ID"
+//	@Param			If-Match	header	string	false	"ETag of the session, the request fails if it has changed"
 //	@Success		204
-//	@Failure		400	{object}	any	"Bad request input"
-//	@Failure		404	{object}	any	"Session not found"
-//	@Failure		500	{object}	any	"Internal server error"
+//	@Failure		400	{object}	problem	"Bad request input"
+//	@Failure		401	{object}	problem	"Unauthorized"
+//	@Failure		403	{object}	problem	"Forbidden"
+//	@Failure		404	{object}	problem	"Session not found"
+//	@Failure		412	{object}	problem	"Session has changed"
+//	@Failure		500	{object}	problem	"Internal server error"
+//	@Security		BearerAuth
 //	@Router			/sessions/{userId}/{taskId} [delete]
 func (app *application) handleSessionStop(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "sessionStop")
-
 	userID, err := userIDFromRequest(r)
 	if err != nil {
 		app.badRequest(w, r, err)
 		return
 	}
 
+	if !app.authorize(w, r, canTrackSessions(userID)) {
+		return
+	}
+
+	app.stopSession(w, r, userID)
+}
+
+// stopSession stops the session of the user for the task from the request.
+func (app *application) stopSession(w http.ResponseWriter, r *http.Request, userID model.ID) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "sessionStop")
+
 	taskID, err := taskIDFromRequest(r)
 	if err != nil {
 		app.badRequest(w, r, err)
 		return
 	}
 
+	ifMatch, ok := ifMatchVersions(r)
+	if !ok {
+		app.preconditionFailed(w, r)
+		return
+	}
+
 	handlerLogger.Debug("read params and body", "userId", userID, "taskId", taskID)
 
-	if _, err := updateSessionEnd(ctx, app.db, baseLogger, userID, taskID); err != nil {
+	if _, err := updateSessionEnd(ctx, app.db, baseLogger, userID, taskID, ifMatch); err != nil {
 		if errors.Is(err, model.ErrNotFound) {
-			app.errorMessage(w, r, http.StatusNotFound, err.Error(), nil)
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, model.ErrVersionMismatch) {
+			app.domainError(w, r, http.StatusPreconditionFailed, err)
 			return
 		}
 
@@ -601,7 +1315,7 @@ func (app *application) handleSessionStop(w http.ResponseWriter, r *http.Request
 
 func updateSessionEnd(
 	ctx context.Context, db *database.DB, logger *slog.Logger,
-	userID model.ID, taskID model.ID,
+	userID model.ID, taskID model.ID, ifMatch []int64,
 ) (model.Session, error) {
 	dao := database.NewSessionDAO(logger, db)
 
@@ -619,7 +1333,8 @@ func updateSessionEnd(
 	logger.Debug("update session", "userId", userID, "taskId", taskID)
 
 	if err := dao.Update(ctx, session.ID, database.UpdateSessionDTO{
-		End: time.Now(),
+		End:     time.Now(),
+		IfMatch: ifMatch,
 	}); err != nil {
 		return model.Session{}, err
 	}
@@ -641,27 +1356,45 @@ func updateSessionEnd(
 // Handle User Stats
 //
 //	@Summary		Users Statistics
-//	@Description	Get users statistics
+//	@Description	Get users statistics, sessions archived by retention are accounted with a month precision
 //	@Tags			users
 //	@Produce		json
 //	@Param			userId	path		int		true	"User ID"
 //	@Param			after	query		string	false	"Start date"	example(2024-06-05 08:00)
 //	@Param			before	query		string	false	"End date"		example(2024-06-20 08:00)
 //	@Success		200		{array}		main.userFormatStat
-//	@Failure		400		{object}	any	"Bad request input"
-//	@Failure		404		{object}	any	"User not found"
-//	@Failure		500		{object}	any	"Internal server error"
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Unauthorized"
+//	@Failure		403		{object}	problem	"Forbidden"
+//	@Failure		404		{object}	problem	"User not found"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Security		BearerAuth
 //	@Router			/users/{userId}/stats [get]
 func (app *application) handleUserStats(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "userStats")
-
 	userID, err := userIDFromRequest(r)
 	if err != nil {
 		app.badRequest(w, r, err)
 		return
 	}
 
+	managed, err := app.managesUser(r, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !app.authorize(w, r, canViewUser(userID, managed)) {
+		return
+	}
+
+	app.respondUserStats(w, r, userID)
+}
+
+// respondUserStats writes the user statistics for the period from the request.
+func (app *application) respondUserStats(w http.ResponseWriter, r *http.Request, userID model.ID) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "userStats")
+
 	opts, err := sessionTimelineOptionsFromRequest(r)
 	if err != nil {
 		app.badRequest(w, r, err)
@@ -672,10 +1405,11 @@ func (app *application) handleUserStats(w http.ResponseWriter, r *http.Request)
 
 	if err := checkUserExists(ctx, app.db, baseLogger, userID); err != nil {
 		if errors.Is(err, model.ErrNotFound) {
-			app.errorMessage(w, r, http.StatusNotFound, err.Error(), nil)
+			app.domainError(w, r, http.StatusNotFound, err)
 			return
 		}
 
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -685,7 +1419,13 @@ func (app *application) handleUserStats(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	stats := mapSessionsToUserFormatStats(sessions, opts)
+	summaries, err := database.NewSessionSummaryDAO(baseLogger, app.db).FindByUsers(ctx, []model.ID{userID}, opts)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	stats := mapSessionsToUserFormatStats(sessions, summaries, opts)
 
 	if err := response.JSON(w, http.StatusOK, stats); err != nil {
 		app.serverError(w, r, err)
@@ -741,15 +1481,25 @@ func findSessions(
 	return sessions, nil
 }
 
-func mapSessionsToUserFormatStats(sessions []model.Session, opts database.SessionTimelineOptions) []userFormatStat {
-	grouped := lo.GroupBy(sessions, func(session model.Session) model.ID {
+// mapSessionsToUserFormatStats sums time per task of raw sessions and summaries of archived ones.
+func mapSessionsToUserFormatStats(
+	sessions []model.Session, summaries []model.SessionSummary,
+	opts database.SessionTimelineOptions,
+) []userFormatStat {
+	amounts := make(map[model.ID]time.Duration)
+	for task, sessions := range lo.GroupBy(sessions, func(session model.Session) model.ID {
 		return session.Task
-	})
+	}) {
+		amounts[task] += calcSumSessions(sessions, opts)
+	}
+	for _, summary := range summaries {
+		amounts[summary.Task] += summary.Duration()
+	}
 
-	stats := lo.MapToSlice(grouped, func(task model.ID, sessions []model.Session) userStat {
+	stats := lo.MapToSlice(amounts, func(task model.ID, amount time.Duration) userStat {
 		return userStat{
 			Task:       task,
-			AmountTime: calcSumSessions(sessions, opts),
+			AmountTime: amount,
 		}
 	})
 
@@ -764,19 +1514,29 @@ func mapSessionsToUserFormatStats(sessions []model.Session, opts database.Sessio
 
 func calcSumSessions(sessions []model.Session, opts database.SessionTimelineOptions) time.Duration {
 	return lo.SumBy(sessions, func(session model.Session) time.Duration {
-		if opts.After != nil && session.Begin.Before(*opts.After) {
-			session.Begin = *opts.After
-		}
+		begin, end := sessionBounds(session, opts)
+		return end.Sub(begin)
+	})
+}
 
-		if session.End == nil || (opts.Before != nil && session.End.After(*opts.Before)) {
-			session.End = new(time.Time)
-			if opts.Before != nil {
-				*session.End = *opts.Before
-			} else {
-				*session.End = time.Now()
-			}
-		}
+// sessionBounds clamps the session to the period, not ended session lasts until now,
+// so the period ending in the future does not count time which has not passed yet.
+func sessionBounds(session model.Session, opts database.SessionTimelineOptions) (time.Time, time.Time) {
+	begin, end := session.Begin, time.Now()
+	if session.End != nil {
+		end = *session.End
+	}
 
-		return session.End.Sub(session.Begin)
-	})
+	if opts.After != nil && begin.Before(*opts.After) {
+		begin = *opts.After
+	}
+	if opts.Before != nil && end.After(*opts.Before) {
+		end = *opts.Before
+	}
+
+	if end.Before(begin) {
+		end = begin
+	}
+
+	return begin, end
 }
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/protomem/time-tracker/internal/auth"
+	"github.com/protomem/time-tracker/internal/database"
+	"github.com/protomem/time-tracker/internal/model"
+	"github.com/protomem/time-tracker/internal/request"
+	"github.com/protomem/time-tracker/internal/response"
+	"github.com/protomem/time-tracker/internal/validator"
+)
+
+// Handle Find API Keys
+//
+//	@Summary		Find API Keys
+//	@Description	Get all API keys with pagination, key secrets are never returned
+//	@Tags			apikeys
+//	@Produce		json
+//	@Param			page		query		int	false	"Page number"	default(1)	minimum(1)
+//	@Param			pageSize	query		int	false	"Page size"		default(10)	minimum(1)
+//	@Success		200			{array}		model.APIKey
+//	@Failure		401			{object}	problem	"Unauthorized"
+//	@Failure		403			{object}	problem	"Forbidden"
+//	@Failure		500			{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/apikeys [get]
+func (app *application) handleFindAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "findAPIKeys")
+
+	if !app.authorize(w, r, canManageAPIKeys) {
+		return
+	}
+
+	opts := findOptionsFromRequest(r)
+
+	handlerLogger.Debug("read params and body", "opts", opts)
+
+	keys, err := database.NewAPIKeyDAO(baseLogger, app.db).Find(ctx, opts)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := response.JSON(w, http.StatusOK, keys); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// Handle Create API Key
+//
+//	@Summary		Create API Key
+//	@Description	Create new API key, the key secret is returned only once.
+//	@Description	Key bound to a user acts with the user role, key without user is a service key with admin rights.
+//	@Description	Passports are unmasked only for keys created with canViewPassports, bound key also needs the permission of the user.
+//	@Description	Only a caller who can view passports can create such key.
+//	@Tags			apikeys
+//	@Accept			json
+//	@Produce		json
+//	@Param			input	body		main.requestCreateAPIKey	true	"API key name"
+//	@Success		201		{object}	main.responseCreatedAPIKey
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Unauthorized"
+//	@Failure		403		{object}	problem	"Forbidden"
+//	@Failure		404		{object}	problem	"User not found"
+//	@Failure		422		{object}	problem	"Invalid input data"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/apikeys [post]
+func (app *application) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "createAPIKey")
+
+	if !app.authorize(w, r, canManageAPIKeys) {
+		return
+	}
+
+	var input requestCreateAPIKey
+	if err := request.DecodeJSONStrict(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if v := validator.Validate(func(v *validator.Validator) {
+		validateAPIKeyName(v, input.Name)
+	}); v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	if input.CanViewPassports && !app.authorize(w, r, canGrantPassportAccess) {
+		return
+	}
+
+	caller, _ := principalFromRequest(r)
+	handlerLogger.Debug("read params and body", "name", input.Name, "userId", input.UserID, "canViewPassports", input.CanViewPassports, "callerApiKeyId", caller.APIKeyID, "callerUserId", caller.UserID)
+
+	key, secret, err := createAPIKey(ctx, app.db, baseLogger, input.Name, input.UserID, input.CanViewPassports)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, model.ErrErased) {
+			app.domainError(w, r, http.StatusConflict, err)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	handlerLogger.Info("api key created", "apiKeyId", key.ID)
+
+	if err := response.JSON(w, http.StatusCreated, responseCreatedAPIKey{APIKey: key, Key: secret}); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+type requestCreateAPIKey struct {
+	Name   string    `json:"name"`
+	UserID *model.ID `json:"userId,omitempty"`
+
+	CanViewPassports bool `json:"canViewPassports,omitempty"`
+}
+
+type responseCreatedAPIKey struct {
+	model.APIKey
+	Key string `json:"key"`
+}
+
+func createAPIKey(
+	ctx context.Context, db *database.DB, logger *slog.Logger,
+	name string, userID *model.ID, canViewPassports bool,
+) (model.APIKey, string, error) {
+	dao := database.NewAPIKeyDAO(logger, db)
+
+	if userID != nil {
+		if err := checkUserActive(ctx, db, logger, *userID); err != nil {
+			return model.APIKey{}, "", err
+		}
+	}
+
+	secret, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		return model.APIKey{}, "", err
+	}
+
+	keyID, err := dao.Insert(ctx, database.InsertAPIKeyDTO{
+		Name:    name,
+		Prefix:  prefix,
+		KeyHash: auth.HashToken(secret),
+		User:    userID,
+
+		CanViewPassports: canViewPassports,
+	})
+	if err != nil {
+		return model.APIKey{}, "", err
+	}
+
+	key, err := dao.Get(ctx, keyID)
+	if err != nil {
+		return model.APIKey{}, "", err
+	}
+
+	return key, secret, nil
+}
+
+// Handle Revoke API Key
+//
+//	@Summary		Revoke API Key
+//	@Description	Revoke API key, revoked keys can no longer be used for authentication
+//	@Tags			apikeys
+//	@Produce		json
+//	@Param			keyId	path	int	true	"API key ID"
+//	@Success		204
+//	@Failure		400	{object}	problem	"Bad request input"
+//	@Failure		401	{object}	problem	"Unauthorized"
+//	@Failure		403	{object}	problem	"Forbidden"
+//	@Failure		404	{object}	problem	"API key not found"
+//	@Failure		500	{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/apikeys/{keyId} [delete]
+func (app *application) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "revokeAPIKey")
+
+	if !app.authorize(w, r, canManageAPIKeys) {
+		return
+	}
+
+	keyID, err := apiKeyIDFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	handlerLogger.Debug("read params and body", "apiKeyId", keyID)
+
+	if err := revokeAPIKey(ctx, app.db, baseLogger, keyID); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	handlerLogger.Info("api key revoked", "apiKeyId", keyID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func revokeAPIKey(ctx context.Context, db *database.DB, logger *slog.Logger, keyID model.ID) error {
+	dao := database.NewAPIKeyDAO(logger, db)
+
+	logger.Debug("check exists api key", "apiKeyId", keyID)
+
+	if _, err := dao.Get(ctx, keyID); err != nil {
+		return err
+	}
+
+	return dao.Revoke(ctx, keyID)
+}
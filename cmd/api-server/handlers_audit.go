@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/protomem/time-tracker/internal/database"
+	"github.com/protomem/time-tracker/internal/model"
+	"github.com/protomem/time-tracker/internal/response"
+	"github.com/protomem/time-tracker/internal/validator"
+)
+
+// Handle Find Audit Entries
+//
+//	@Summary		Find Audit Entries
+//	@Description	Get audit log of created, updated and deleted users and sessions, newest first.
+//	@Description	Archived batches of sessions are recorded as deleted sessionArchive with the list of session IDs.
+//	@Description	For updates before and after contain only changed fields.
+//	@Tags			audit
+//	@Produce		json
+//	@Param			entity			query		string	false	"Entity"	Enums(user, session, sessionArchive)
+//	@Param			entityId		query		int		false	"Entity ID"
+//	@Param			action			query		string	false	"Action"	Enums(create, update, delete)
+//	@Param			actorUserId		query		int		false	"Actor user ID"
+//	@Param			actorApiKeyId	query		int		false	"Actor API key ID"
+//	@Param			after			query		string	false	"Start date"	example(2024-06-05 08:00)
+//	@Param			before			query		string	false	"End date"		example(2024-06-20 08:00)
+//	@Param			page			query		int		false	"Page number"	default(1)	minimum(1)
+//	@Param			pageSize		query		int		false	"Page size"		default(10)	minimum(1)
+//	@Success		200				{array}		model.AuditEntry
+//	@Failure		400				{object}	problem	"Bad request input"
+//	@Failure		401				{object}	problem	"Unauthorized"
+//	@Failure		403				{object}	problem	"Forbidden"
+//	@Failure		422				{object}	problem	"Invalid input data"
+//	@Failure		500				{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/audit [get]
+func (app *application) handleFindAuditEntries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "findAuditEntries")
+
+	if !app.authorize(w, r, canViewAudit) {
+		return
+	}
+
+	filter, err := findAuditFilterFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if v := validator.Validate(func(v *validator.Validator) {
+		if filter.Entity != nil {
+			v.CheckField(validator.In(*filter.Entity, database.AuditEntityUser, database.AuditEntitySession, database.AuditEntitySessionArchive), "entity", "must be one of user, session, sessionArchive")
+		}
+		if filter.Action != nil {
+			v.CheckField(validator.In(*filter.Action, model.AuditActions...), "action", "must be one of create, update, delete")
+		}
+	}); v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	opts := findOptionsFromRequest(r)
+
+	handlerLogger.Debug("read params and body", "filter", filter, "opts", opts)
+
+	entries, err := database.NewAuditDAO(baseLogger, app.db).Find(ctx, filter, opts)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := response.JSON(w, http.StatusOK, entries); err != nil {
+		app.serverError(w, r, err)
+	}
+}
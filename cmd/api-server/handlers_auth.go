@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/protomem/time-tracker/internal/auth"
+	"github.com/protomem/time-tracker/internal/database"
+	"github.com/protomem/time-tracker/internal/model"
+	"github.com/protomem/time-tracker/internal/request"
+	"github.com/protomem/time-tracker/internal/response"
+	"github.com/protomem/time-tracker/internal/validator"
+)
+
+// Handle Create Login Code
+//
+//	@Summary		Create Login Code
+//	@Description	Create one-time login code for the user, the code is returned only once
+//	@Tags			auth
+//	@Produce		json
+//	@Param			userId	path		int	true	"User ID"
+//	@Success		201		{object}	main.responseLoginCode
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Unauthorized"
+//	@Failure		403		{object}	problem	"Forbidden"
+//	@Failure		404		{object}	problem	"User not found"
+//	@Failure		409		{object}	problem	"User erased"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/users/{userId}/login-codes [post]
+func (app *application) handleCreateLoginCode(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "createLoginCode")
+
+	if !app.authorize(w, r, canManageUsers) {
+		return
+	}
+
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	handlerLogger.Debug("read params and body", "userId", userID)
+
+	code, expiresAt, err := createLoginCode(ctx, app.db, baseLogger, userID, app.config.Auth.LoginCodeTTL)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, model.ErrErased) {
+			app.domainError(w, r, http.StatusConflict, err)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := response.JSON(w, http.StatusCreated, responseLoginCode{Code: code, ExpiresAt: expiresAt}); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+type responseLoginCode struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func createLoginCode(
+	ctx context.Context, db *database.DB, logger *slog.Logger,
+	userID model.ID, ttl time.Duration,
+) (string, time.Time, error) {
+	if err := checkUserActive(ctx, db, logger, userID); err != nil {
+		return "", time.Time{}, err
+	}
+
+	organizationID, ok := database.TenantFromContext(ctx)
+	if !ok {
+		return "", time.Time{}, database.ErrTenantRequired
+	}
+
+	code, err := auth.GenerateLoginCode()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(ttl)
+
+	if _, err := database.NewLoginCodeDAO(logger, db).Insert(ctx, database.InsertLoginCodeDTO{
+		Organization: organizationID,
+		User:         userID,
+		CodeHash:     auth.HashToken(code),
+		ExpiresAt:    expiresAt,
+	}); err != nil {
+		return "", time.Time{}, err
+	}
+
+	logger.Debug("login code created", "userId", userID)
+
+	return code, expiresAt, nil
+}
+
+// Handle Login
+//
+//	@Summary		Login
+//	@Description	Exchange one-time login code for access and refresh tokens
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			input	body		main.requestLogin	true	"One-time login code"
+//	@Success		200		{object}	main.responseTokens
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Invalid or expired login code"
+//	@Failure		422		{object}	problem	"Invalid input data"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Router			/auth/login [post]
+func (app *application) handleLogin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "login")
+
+	var input requestLogin
+	if err := request.DecodeJSONStrict(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Code = strings.ToUpper(strings.TrimSpace(input.Code))
+
+	if v := validator.Validate(func(v *validator.Validator) {
+		v.CheckField(validator.NotBlank(input.Code), "code", "cannot be blank")
+	}); v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	tokens, err := login(ctx, app.db, baseLogger, app.tokens, app.config.Auth.RefreshTokenTTL, input.Code)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.invalidCredentials(w, r)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	handlerLogger.Info("user logged in", "sessionId", tokens.SessionID)
+
+	if err := response.JSON(w, http.StatusOK, tokens); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+type requestLogin struct {
+	Code string `json:"code"`
+}
+
+type responseTokens struct {
+	TokenType        string    `json:"tokenType"`
+	AccessToken      string    `json:"accessToken"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	RefreshToken     string    `json:"refreshToken"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+	SessionID        model.ID  `json:"sessionId"`
+}
+
+func login(
+	ctx context.Context, db *database.DB, logger *slog.Logger,
+	tokens *auth.TokenIssuer, refreshTTL time.Duration,
+	code string,
+) (responseTokens, error) {
+	loginCode, err := database.NewLoginCodeDAO(logger, db).Consume(ctx, auth.HashToken(code))
+	if err != nil {
+		return responseTokens{}, err
+	}
+
+	session := model.AuthSession{
+		Organization: loginCode.Organization,
+		User:         loginCode.User,
+		ExpiresAt:    time.Now().Add(refreshTTL),
+	}
+
+	session.ID, err = database.NewAuthSessionDAO(logger, db).Insert(ctx, database.InsertAuthSessionDTO{
+		Organization: session.Organization,
+		User:         session.User,
+		ExpiresAt:    session.ExpiresAt,
+	})
+	if err != nil {
+		return responseTokens{}, err
+	}
+
+	return issueTokens(ctx, db, logger, tokens, session)
+}
+
+func issueTokens(
+	ctx context.Context, db *database.DB, logger *slog.Logger,
+	tokens *auth.TokenIssuer, session model.AuthSession,
+) (responseTokens, error) {
+	refreshToken, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return responseTokens{}, err
+	}
+
+	if _, err := database.NewAuthSessionDAO(logger, db).InsertRefreshToken(ctx, session.ID, auth.HashToken(refreshToken)); err != nil {
+		return responseTokens{}, err
+	}
+
+	accessToken, expiresAt, err := tokens.Issue(session.User, session.ID)
+	if err != nil {
+		return responseTokens{}, err
+	}
+
+	return responseTokens{
+		TokenType:        "Bearer",
+		AccessToken:      accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: session.ExpiresAt,
+		SessionID:        session.ID,
+	}, nil
+}
+
+// Handle Refresh Tokens
+//
+//	@Summary		Refresh Tokens
+//	@Description	Exchange refresh token for new access and refresh tokens. Refresh tokens are single-use:
+//	@Description	reusing an already rotated token revokes the whole login session.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			input	body		main.requestRefreshToken	true	"Refresh token"
+//	@Success		200		{object}	main.responseTokens
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Invalid or revoked refresh token"
+//	@Failure		422		{object}	problem	"Invalid input data"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Router			/auth/refresh [post]
+func (app *application) handleRefreshTokens(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "refreshTokens")
+
+	var input requestRefreshToken
+	if err := request.DecodeJSONStrict(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if v := validator.Validate(func(v *validator.Validator) {
+		v.CheckField(validator.NotBlank(input.RefreshToken), "refreshToken", "cannot be blank")
+	}); v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	tokens, err := rotateTokens(ctx, app.db, baseLogger, app.tokens, input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.invalidAuthenticationToken(w, r)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	handlerLogger.Debug("tokens refreshed", "sessionId", tokens.SessionID)
+
+	if err := response.JSON(w, http.StatusOK, tokens); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+type requestRefreshToken struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+func rotateTokens(
+	ctx context.Context, db *database.DB, logger *slog.Logger,
+	tokens *auth.TokenIssuer, refreshToken string,
+) (responseTokens, error) {
+	dao := database.NewAuthSessionDAO(logger, db)
+
+	token, session, err := findRefreshTokenSession(ctx, dao, refreshToken)
+	if err != nil {
+		return responseTokens{}, err
+	}
+
+	used, err := dao.UseRefreshToken(ctx, token.ID)
+	if err != nil {
+		return responseTokens{}, err
+	}
+
+	if !used {
+		logger.Warn("refresh token reuse detected, revoking session", "sessionId", session.ID)
+
+		if err := dao.Revoke(ctx, session.ID); err != nil {
+			return responseTokens{}, err
+		}
+
+		return responseTokens{}, model.NewError("refresh token", model.ErrNotFound)
+	}
+
+	return issueTokens(ctx, db, logger, tokens, session)
+}
+
+func findRefreshTokenSession(
+	ctx context.Context, dao *database.AuthSessionDAO,
+	refreshToken string,
+) (model.RefreshToken, model.AuthSession, error) {
+	token, err := dao.GetRefreshToken(ctx, auth.HashToken(refreshToken))
+	if err != nil {
+		return model.RefreshToken{}, model.AuthSession{}, err
+	}
+
+	session, err := dao.Get(ctx, token.Session)
+	if err != nil {
+		return model.RefreshToken{}, model.AuthSession{}, err
+	}
+
+	if !session.Active(time.Now()) {
+		return model.RefreshToken{}, model.AuthSession{}, model.NewError("refresh token", model.ErrNotFound)
+	}
+
+	return token, session, nil
+}
+
+// Handle Logout
+//
+//	@Summary		Logout
+//	@Description	Revoke login session of the refresh token, issued access tokens stop working immediately
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			input	body	main.requestRefreshToken	true	"Refresh token"
+//	@Success		204
+//	@Failure		400	{object}	problem	"Bad request input"
+//	@Failure		401	{object}	problem	"Invalid or revoked refresh token"
+//	@Failure		422	{object}	problem	"Invalid input data"
+//	@Failure		500	{object}	problem	"Internal server error"
+//	@Router			/auth/logout [post]
+func (app *application) handleLogout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "logout")
+
+	var input requestRefreshToken
+	if err := request.DecodeJSONStrict(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if v := validator.Validate(func(v *validator.Validator) {
+		v.CheckField(validator.NotBlank(input.RefreshToken), "refreshToken", "cannot be blank")
+	}); v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	dao := database.NewAuthSessionDAO(baseLogger, app.db)
+
+	_, session, err := findRefreshTokenSession(ctx, dao, input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.invalidAuthenticationToken(w, r)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := dao.Revoke(ctx, session.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	handlerLogger.Info("user logged out", "sessionId", session.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handle Revoke User Sessions
+//
+//	@Summary		Revoke User Sessions
+//	@Description	Revoke all login sessions of the user
+//	@Tags			auth
+//	@Produce		json
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	problem	"Bad request input"
+//	@Failure		401	{object}	problem	"Unauthorized"
+//	@Failure		403	{object}	problem	"Forbidden"
+//	@Failure		404	{object}	problem	"User not found"
+//	@Failure		500	{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/users/{userId}/auth-sessions [delete]
+func (app *application) handleRevokeUserAuthSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "revokeUserAuthSessions")
+
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if !app.authorize(w, r, canManageSessions(userID)) {
+		return
+	}
+
+	handlerLogger.Debug("read params and body", "userId", userID)
+
+	if err := checkUserExists(ctx, app.db, baseLogger, userID); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := database.NewAuthSessionDAO(baseLogger, app.db).RevokeByUser(ctx, userID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	handlerLogger.Info("user sessions revoked", "userId", userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/protomem/time-tracker/internal/identity"
+	"github.com/protomem/time-tracker/internal/response"
+	"github.com/protomem/time-tracker/internal/version"
+)
+
+const (
+	_healthCheckTimeout = 2 * time.Second
+
+	_healthStatusOK   = "ok"
+	_healthStatusFail = "fail"
+)
+
+type responseHealth struct {
+	Status  string                       `json:"status"`
+	Version string                       `json:"version"`
+	Checks  map[string]healthCheckResult `json:"checks,omitempty"`
+}
+
+type healthCheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// handleLivez reports that the process is running, it does not check dependencies.
+func (app *application) handleLivez(w http.ResponseWriter, r *http.Request) {
+	if err := response.JSON(w, http.StatusOK, responseHealth{Status: _healthStatusOK, Version: version.Get()}); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// handleReadyz reports whether the server can handle requests: the database is reachable and migrated
+// and, if enabled, the people service is reachable. It responds with 503 if any check fails.
+func (app *application) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	_, handlerLogger := app.buildHandlerLoggers(r, "readyz")
+
+	checks := map[string]func(ctx context.Context) error{
+		"database":  app.db.PingContext,
+		"migration": app.db.CheckMigration,
+	}
+	if checker, ok := app.identity.(identity.Checker); ok && app.config.Health.CheckPeopleService {
+		checks["peopleService"] = checker.Check
+	}
+
+	results := runHealthChecks(r.Context(), checks)
+
+	status, code := _healthStatusOK, http.StatusOK
+	for name, result := range results {
+		if result.Status != _healthStatusOK {
+			status, code = _healthStatusFail, http.StatusServiceUnavailable
+			handlerLogger.Warn("readiness check failed", "check", name, "error", result.Error)
+		}
+	}
+
+	if err := response.JSON(w, code, responseHealth{Status: status, Version: version.Get(), Checks: results}); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func runHealthChecks(ctx context.Context, checks map[string]func(ctx context.Context) error) map[string]healthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, _healthCheckTimeout)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]healthCheckResult, len(checks))
+	)
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+
+			result := healthCheckResult{Status: _healthStatusOK, DurationMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				result.Status, result.Error = _healthStatusFail, err.Error()
+			}
+
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+// meFromRequest resolves the user of the authenticated principal,
+// writes 403 response and reports false if the principal is not bound to a user.
+func (app *application) meFromRequest(w http.ResponseWriter, r *http.Request) (model.ID, bool) {
+	p, ok := principalFromRequest(r)
+	if !ok || p.UserID == nil {
+		app.principalNotBound(w, r)
+		return 0, false
+	}
+
+	return *p.UserID, true
+}
+
+// Handle Get Me
+//
+//	@Summary		Get Me
+//	@Description	Get profile of the authenticated user
+//	@Tags			me
+//	@Produce		json
+//	@Param			If-None-Match	header	string	false	"ETag of the cached representation"
+//	@Success		200	{object}	model.User
+//	@Header			200	{string}	ETag	"Tag of the representation"
+//	@Success		304	"Not modified"
+//	@Failure		401	{object}	problem	"Unauthorized"
+//	@Failure		403	{object}	problem	"Principal is not bound to a user"
+//	@Failure		404	{object}	problem	"User not found"
+//	@Failure		500	{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/me [get]
+func (app *application) handleGetMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.meFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	app.respondUser(w, r, userID)
+}
+
+// Handle Find My Sessions
+//
+//	@Summary		Find My Sessions
+//	@Description	Find sessions of the authenticated user
+//	@Tags			me
+//	@Produce		json
+//	@Param			If-None-Match	header	string	false	"ETag of the cached representation"
+//	@Success		200	{object}	[]model.Session
+//	@Header			200	{string}	ETag	"Tag of the representation"
+//	@Success		304	"Not modified"
+//	@Failure		401	{object}	problem	"Unauthorized"
+//	@Failure		403	{object}	problem	"Principal is not bound to a user"
+//	@Failure		404	{object}	problem	"User not found"
+//	@Failure		500	{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/me/sessions [get]
+func (app *application) handleFindMySessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.meFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	app.respondSessions(w, r, userID)
+}
+
+// Handle Export Me
+//
+//	@Summary		Export Me
+//	@Description	Export all personal data of the authenticated user as a JSON archive
+//	@Tags			me
+//	@Produce		json
+//	@Success		200	{object}	main.responseUserExport
+//	@Failure		401	{object}	problem	"Unauthorized"
+//	@Failure		403	{object}	problem	"Principal is not bound to a user"
+//	@Failure		404	{object}	problem	"User not found"
+//	@Failure		500	{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/me/export [get]
+func (app *application) handleExportMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.meFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	app.respondUserExport(w, r, userID)
+}
+
+// Handle My Stats
+//
+//	@Summary		My Statistics
+//	@Description	Get statistics of the authenticated user
+//	@Tags			me
+//	@Produce		json
+//	@Param			after	query		string	false	"Start date"	example(2024-06-05 08:00)
+//	@Param			before	query		string	false	"End date"		example(2024-06-20 08:00)
+//	@Success		200		{array}		main.userFormatStat
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Unauthorized"
+//	@Failure		403		{object}	problem	"Principal is not bound to a user"
+//	@Failure		404		{object}	problem	"User not found"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/me/stats [get]
+func (app *application) handleMyStats(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.meFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	app.respondUserStats(w, r, userID)
+}
+
+// Handle My Session Start
+//
+//	@Summary		Start My Session
+//	@Description	Start new session of the authenticated user
+//	@Tags			me
+//	@Produce		json
+//	@Param			taskId	path	int	true	"Task ID"
+//	@Param			Idempotency-Key	header	string	false	"Key to safely retry the request"
+//	@Success		201
+//	@Header			201	{string}	ETag	"Tag of the started session"
+//	@Failure		400	{object}	problem	"Bad request input"
+//	@Failure		401	{object}	problem	"Unauthorized"
+//	@Failure		403	{object}	problem	"Principal is not bound to a user"
+//	@Failure		404	{object}	problem	"User not found"
+//	@Failure		409	{object}	problem	"Session already exists"
+//	@Failure		422	{object}	problem	"Idempotency key reused"
+//	@Failure		500	{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/me/tasks/{taskId}/start [post]
+func (app *application) handleMySessionStart(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.meFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	app.startSession(w, r, userID)
+}
+
+// Handle My Session Stop
+//
+//	@Summary		Stop My Session
+//	@Description	Stop session of the authenticated user
+//	@Tags			me
+//	@Produce		json
+//	@Param			taskId	path	int	true	"Task ID"
+//	@Param			If-Match	header	string	false	"ETag of the session, the request fails if it has changed"
+//	@Success		204
+//	@Failure		400	{object}	problem	"Bad request input"
+//	@Failure		401	{object}	problem	"Unauthorized"
+//	@Failure		403	{object}	problem	"Principal is not bound to a user"
+//	@Failure		404	{object}	problem	"Session not found"
+//	@Failure		412	{object}	problem	"Session has changed"
+//	@Failure		500	{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/me/tasks/{taskId}/stop [post]
+func (app *application) handleMySessionStop(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.meFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	app.stopSession(w, r, userID)
+}
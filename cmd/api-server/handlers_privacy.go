@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/protomem/time-tracker/internal/database"
+	"github.com/protomem/time-tracker/internal/model"
+	"github.com/protomem/time-tracker/internal/response"
+)
+
+const _exportPageSize = 100
+
+// Handle Export User
+//
+//	@Summary		Export User
+//	@Description	Export all personal data of the user (profile, change history, sessions and monthly summaries of archived sessions) as a JSON archive
+//	@Tags			privacy
+//	@Produce		json
+//	@Param			userId	path		int	true	"User ID"
+//	@Success		200		{object}	main.responseUserExport
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Unauthorized"
+//	@Failure		403		{object}	problem	"Forbidden"
+//	@Failure		404		{object}	problem	"User not found"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/users/{userId}/export [get]
+func (app *application) handleExportUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if !app.authorize(w, r, canExportUser(userID)) {
+		return
+	}
+
+	app.respondUserExport(w, r, userID)
+}
+
+// respondUserExport writes the archive as an attachment, the caller must be authorized to export the user.
+func (app *application) respondUserExport(w http.ResponseWriter, r *http.Request, userID model.ID) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "exportUser")
+
+	handlerLogger.Debug("read params and body", "userId", userID)
+
+	export, err := exportUser(ctx, app.db, baseLogger, userID)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	export.User = presentUser(r, export.User)
+
+	handlerLogger.Debug("user exported", "userId", userID, "countSessions", len(export.Sessions))
+
+	headers := http.Header{"Content-Disposition": []string{fmt.Sprintf(`attachment; filename="user-%d.json"`, userID)}}
+	if err := response.JSONWithHeaders(w, http.StatusOK, export, headers); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+type responseUserExport struct {
+	ExportedAt time.Time          `json:"exportedAt"`
+	User       model.User         `json:"user"`
+	Changes    []model.UserChange `json:"changes"`
+	Sessions   []model.Session    `json:"sessions"`
+
+	SessionSummaries []model.SessionSummary `json:"sessionSummaries"`
+}
+
+func exportUser(
+	ctx context.Context, db *database.DB, logger *slog.Logger,
+	userID model.ID,
+) (responseUserExport, error) {
+	user, err := getUser(ctx, db, logger, userID)
+	if err != nil {
+		return responseUserExport{}, err
+	}
+
+	changes := make([]model.UserChange, 0)
+	opts := database.FindOptions{Limit: _exportPageSize}
+	for {
+		page, err := database.NewUserChangeDAO(logger, db).FindByUser(ctx, userID, opts)
+		if err != nil {
+			return responseUserExport{}, err
+		}
+		changes = append(changes, page...)
+
+		if uint64(len(page)) < opts.Limit {
+			break
+		}
+		opts.Offset += opts.Limit
+	}
+
+	sessions, err := findSessions(ctx, db, logger, userID, database.SessionTimelineOptions{})
+	if err != nil {
+		return responseUserExport{}, err
+	}
+
+	summaries, err := database.NewSessionSummaryDAO(logger, db).FindByUsers(ctx, []model.ID{userID}, database.SessionTimelineOptions{})
+	if err != nil {
+		return responseUserExport{}, err
+	}
+
+	return responseUserExport{
+		ExportedAt:       time.Now(),
+		User:             user,
+		Changes:          changes,
+		Sessions:         sessions,
+		SessionSummaries: summaries,
+	}, nil
+}
+
+// Handle Erase User
+//
+//	@Summary		Erase User
+//	@Description	Anonymise personal data of the user on a subject request. Personal fields and passport are cleared,
+//	@Description	open sessions are stopped, login sessions, API keys, team memberships and change history are deleted
+//	@Description	and personal data is redacted from the audit log. Sessions are kept for reporting,
+//	@Description	they refer only to the de-identified user. The operation can not be undone.
+//	@Tags			privacy
+//	@Produce		json
+//	@Param			userId	path		int	true	"User ID"
+//	@Success		200		{object}	model.User
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Unauthorized"
+//	@Failure		403		{object}	problem	"Forbidden"
+//	@Failure		404		{object}	problem	"User not found"
+//	@Failure		409		{object}	problem	"User already erased"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/users/{userId}/erase [post]
+func (app *application) handleEraseUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "eraseUser")
+
+	if !app.authorize(w, r, canManageUsers) {
+		return
+	}
+
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	handlerLogger.Debug("read params and body", "userId", userID)
+
+	user, err := eraseUser(ctx, app.db, baseLogger, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			app.domainError(w, r, http.StatusNotFound, err)
+		case errors.Is(err, model.ErrErased):
+			app.domainError(w, r, http.StatusConflict, err)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	handlerLogger.Info("user erased", "userId", userID)
+
+	if err := response.JSON(w, http.StatusOK, user); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func eraseUser(
+	ctx context.Context, db *database.DB, logger *slog.Logger,
+	userID model.ID,
+) (model.User, error) {
+	dao := database.NewUserDAO(logger, db)
+
+	if _, err := dao.Erase(ctx, userID); err != nil {
+		return model.User{}, err
+	}
+
+	return dao.Get(ctx, userID)
+}
+
+// checkUserActive fails for missing and erased users, erased users can not track time or log in.
+func checkUserActive(ctx context.Context, db *database.DB, logger *slog.Logger, userID model.ID) error {
+	user, err := getUser(ctx, db, logger, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.ErasedAt != nil {
+		return model.NewError("user", model.ErrErased)
+	}
+
+	return nil
+}
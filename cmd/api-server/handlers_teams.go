@@ -0,0 +1,527 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/protomem/time-tracker/internal/database"
+	"github.com/protomem/time-tracker/internal/model"
+	"github.com/protomem/time-tracker/internal/request"
+	"github.com/protomem/time-tracker/internal/response"
+	"github.com/protomem/time-tracker/internal/validator"
+	"github.com/samber/lo"
+)
+
+const _dayLayout = "2006-01-02"
+
+// Handle Find Teams
+//
+//	@Summary		Find Teams
+//	@Description	Get all teams of the organization with pagination, managers get only the teams they manage
+//	@Tags			teams
+//	@Produce		json
+//	@Param			page		query		int	false	"Page number"	default(1)	minimum(1)
+//	@Param			pageSize	query		int	false	"Page size"		default(10)	minimum(1)
+//	@Success		200			{array}		model.Team
+//	@Failure		401			{object}	problem	"Unauthorized"
+//	@Failure		403			{object}	problem	"Forbidden"
+//	@Failure		500			{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/teams [get]
+func (app *application) handleFindTeams(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "findTeams")
+
+	if !app.authorize(w, r, canListUsers) {
+		return
+	}
+
+	opts := findOptionsFromRequest(r)
+
+	var filter database.FindTeamFilter
+	if p, _ := principalFromRequest(r); !p.isAdmin() {
+		filter.ManagedBy = p.UserID
+	}
+
+	handlerLogger.Debug("read params and body", "filter", filter, "opts", opts)
+
+	teams, err := database.NewTeamDAO(baseLogger, app.db).Find(ctx, filter, opts)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := response.JSON(w, http.StatusOK, teams); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// Handle Create Team
+//
+//	@Summary		Create Team
+//	@Description	Create new team
+//	@Tags			teams
+//	@Accept			json
+//	@Produce		json
+//	@Param			input	body		main.requestCreateTeam	true	"Team name"
+//	@Success		201		{object}	model.Team
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Unauthorized"
+//	@Failure		403		{object}	problem	"Forbidden"
+//	@Failure		409		{object}	problem	"Team already exists"
+//	@Failure		422		{object}	problem	"Invalid input data"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/teams [post]
+func (app *application) handleCreateTeam(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "createTeam")
+
+	if !app.authorize(w, r, canManageTeams) {
+		return
+	}
+
+	var input requestCreateTeam
+	if err := request.DecodeJSONStrict(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if v := validator.Validate(func(v *validator.Validator) {
+		validateTeamName(v, input.Name)
+	}); v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	handlerLogger.Debug("read params and body", "name", input.Name)
+
+	team, err := createTeam(ctx, app.db, baseLogger, input.Name)
+	if err != nil {
+		if errors.Is(err, model.ErrExists) {
+			app.domainError(w, r, http.StatusConflict, err)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := response.JSON(w, http.StatusCreated, team); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+type requestCreateTeam struct {
+	Name string `json:"name"`
+}
+
+func createTeam(ctx context.Context, db *database.DB, logger *slog.Logger, name string) (model.Team, error) {
+	dao := database.NewTeamDAO(logger, db)
+
+	teamID, err := dao.Insert(ctx, name)
+	if err != nil {
+		return model.Team{}, err
+	}
+
+	return dao.Get(ctx, teamID)
+}
+
+// Handle Delete Team
+//
+//	@Summary		Delete Team
+//	@Description	Delete team, members stay in the organization
+//	@Tags			teams
+//	@Produce		json
+//	@Param			teamId	path	int	true	"Team ID"
+//	@Success		204
+//	@Failure		400	{object}	problem	"Bad request input"
+//	@Failure		401	{object}	problem	"Unauthorized"
+//	@Failure		403	{object}	problem	"Forbidden"
+//	@Failure		404	{object}	problem	"Team not found"
+//	@Failure		500	{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/teams/{teamId} [delete]
+func (app *application) handleDeleteTeam(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "deleteTeam")
+
+	if !app.authorize(w, r, canManageTeams) {
+		return
+	}
+
+	teamID, err := teamIDFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	handlerLogger.Debug("read params and body", "teamId", teamID)
+
+	dao := database.NewTeamDAO(baseLogger, app.db)
+
+	if _, err := dao.Get(ctx, teamID); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := dao.Delete(ctx, teamID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handle Find Team Members
+//
+//	@Summary		Find Team Members
+//	@Description	Get members and managers of the team
+//	@Tags			teams
+//	@Produce		json
+//	@Param			teamId	path		int	true	"Team ID"
+//	@Success		200		{array}		model.TeamMember
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Unauthorized"
+//	@Failure		403		{object}	problem	"Forbidden"
+//	@Failure		404		{object}	problem	"Team not found"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/teams/{teamId}/members [get]
+func (app *application) handleFindTeamMembers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "findTeamMembers")
+
+	teamID, err := teamIDFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	managed, err := app.managesTeam(r, teamID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !app.authorize(w, r, canViewTeam(managed)) {
+		return
+	}
+
+	handlerLogger.Debug("read params and body", "teamId", teamID)
+
+	members, err := findTeamMembers(ctx, app.db, baseLogger, teamID)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := response.JSON(w, http.StatusOK, members); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func findTeamMembers(ctx context.Context, db *database.DB, logger *slog.Logger, teamID model.ID) ([]model.TeamMember, error) {
+	dao := database.NewTeamDAO(logger, db)
+
+	if _, err := dao.Get(ctx, teamID); err != nil {
+		return []model.TeamMember{}, err
+	}
+
+	return dao.FindMembers(ctx, teamID)
+}
+
+// Handle Set Team Member
+//
+//	@Summary		Set Team Member
+//	@Description	Add user to the team or change the role of the member
+//	@Tags			teams
+//	@Accept			json
+//	@Produce		json
+//	@Param			teamId	path	int						true	"Team ID"
+//	@Param			userId	path	int						true	"User ID"
+//	@Param			input	body	main.requestSetTeamMember	true	"Member role"
+//	@Success		204
+//	@Failure		400	{object}	problem	"Bad request input"
+//	@Failure		401	{object}	problem	"Unauthorized"
+//	@Failure		403	{object}	problem	"Forbidden"
+//	@Failure		404	{object}	problem	"Team or user not found"
+//	@Failure		422	{object}	problem	"Invalid input data"
+//	@Failure		500	{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/teams/{teamId}/members/{userId} [put]
+func (app *application) handleSetTeamMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "setTeamMember")
+
+	if !app.authorize(w, r, canManageTeams) {
+		return
+	}
+
+	teamID, err := teamIDFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input := requestSetTeamMember{Role: model.TeamRoleMember}
+	if err := request.DecodeJSONStrict(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if v := validator.Validate(func(v *validator.Validator) {
+		validateTeamRole(v, input.Role)
+	}); v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	handlerLogger.Debug("read params and body", "teamId", teamID, "userId", userID, "role", input.Role)
+
+	if err := setTeamMember(ctx, app.db, baseLogger, teamID, userID, input.Role); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, model.ErrErased) {
+			app.domainError(w, r, http.StatusConflict, err)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type requestSetTeamMember struct {
+	Role model.TeamRole `json:"role"`
+}
+
+func setTeamMember(
+	ctx context.Context, db *database.DB, logger *slog.Logger,
+	teamID model.ID, userID model.ID, role model.TeamRole,
+) error {
+	dao := database.NewTeamDAO(logger, db)
+
+	if _, err := dao.Get(ctx, teamID); err != nil {
+		return err
+	}
+
+	if err := checkUserActive(ctx, db, logger, userID); err != nil {
+		return err
+	}
+
+	return dao.SetMember(ctx, teamID, userID, role)
+}
+
+// Handle Remove Team Member
+//
+//	@Summary		Remove Team Member
+//	@Description	Remove user from the team
+//	@Tags			teams
+//	@Produce		json
+//	@Param			teamId	path	int	true	"Team ID"
+//	@Param			userId	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	problem	"Bad request input"
+//	@Failure		401	{object}	problem	"Unauthorized"
+//	@Failure		403	{object}	problem	"Forbidden"
+//	@Failure		404	{object}	problem	"Team or member not found"
+//	@Failure		500	{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/teams/{teamId}/members/{userId} [delete]
+func (app *application) handleRemoveTeamMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "removeTeamMember")
+
+	if !app.authorize(w, r, canManageTeams) {
+		return
+	}
+
+	teamID, err := teamIDFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	handlerLogger.Debug("read params and body", "teamId", teamID, "userId", userID)
+
+	if err := removeTeamMember(ctx, app.db, baseLogger, teamID, userID); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func removeTeamMember(
+	ctx context.Context, db *database.DB, logger *slog.Logger,
+	teamID model.ID, userID model.ID,
+) error {
+	dao := database.NewTeamDAO(logger, db)
+
+	if _, err := dao.Get(ctx, teamID); err != nil {
+		return err
+	}
+
+	return dao.RemoveMember(ctx, teamID, userID)
+}
+
+// Handle Team Stats
+//
+//	@Summary		Team Statistics
+//	@Description	Get time of all team members aggregated per task and per day (UTC).
+//	@Description	Per task time includes sessions archived by retention, per day time includes only raw sessions.
+//	@Tags			teams
+//	@Produce		json
+//	@Param			teamId	path		int		true	"Team ID"
+//	@Param			after	query		string	false	"Start date"	example(2024-06-05 08:00)
+//	@Param			before	query		string	false	"End date"		example(2024-06-20 08:00)
+//	@Success		200		{object}	main.responseTeamStats
+//	@Failure		400		{object}	problem	"Bad request input"
+//	@Failure		401		{object}	problem	"Unauthorized"
+//	@Failure		403		{object}	problem	"Forbidden"
+//	@Failure		404		{object}	problem	"Team not found"
+//	@Failure		500		{object}	problem	"Internal server error"
+//	@Security		BearerAuth
+//	@Router			/teams/{teamId}/stats [get]
+func (app *application) handleTeamStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	baseLogger, handlerLogger := app.buildHandlerLoggers(r, "teamStats")
+
+	teamID, err := teamIDFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	managed, err := app.managesTeam(r, teamID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !app.authorize(w, r, canViewTeam(managed)) {
+		return
+	}
+
+	opts, err := sessionTimelineOptionsFromRequest(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	handlerLogger.Debug("read params and body", "teamId", teamID, "opts", opts)
+
+	members, err := findTeamMembers(ctx, app.db, baseLogger, teamID)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			app.domainError(w, r, http.StatusNotFound, err)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	stats := responseTeamStats{Tasks: []userFormatStat{}, Days: []teamDayStat{}}
+
+	if len(members) != 0 {
+		userIDs := lo.Map(members, func(member model.TeamMember, _ int) model.ID {
+			return member.User
+		})
+
+		sessions, err := database.NewSessionDAO(baseLogger, app.db).FindByUsers(ctx, userIDs, opts)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		summaries, err := database.NewSessionSummaryDAO(baseLogger, app.db).FindByUsers(ctx, userIDs, opts)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		stats.Tasks = mapSessionsToUserFormatStats(sessions, summaries, opts)
+		stats.Days = mapSessionsToDayStats(sessions, opts)
+	}
+
+	if err := response.JSON(w, http.StatusOK, stats); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+type responseTeamStats struct {
+	Tasks []userFormatStat `json:"tasks"`
+	Days  []teamDayStat    `json:"days"`
+}
+
+type teamDayStat struct {
+	Day        string `json:"day" example:"2024-06-05"`
+	AmountTime string `json:"amountTime"`
+}
+
+// mapSessionsToDayStats splits sessions at UTC midnight and sums time per day.
+func mapSessionsToDayStats(sessions []model.Session, opts database.SessionTimelineOptions) []teamDayStat {
+	amounts := make(map[time.Time]time.Duration)
+	for _, session := range sessions {
+		begin, end := sessionBounds(session, opts)
+		begin, end = begin.UTC(), end.UTC()
+
+		for begin.Before(end) {
+			day := time.Date(begin.Year(), begin.Month(), begin.Day(), 0, 0, 0, 0, time.UTC)
+			next := day.AddDate(0, 0, 1)
+
+			amounts[day] += lo.Ternary(end.Before(next), end, next).Sub(begin)
+			begin = next
+		}
+	}
+
+	days := lo.Keys(amounts)
+	slices.SortFunc(days, func(a, b time.Time) int {
+		return cmp.Compare(a.Unix(), b.Unix())
+	})
+
+	return lo.Map(days, func(day time.Time, _ int) teamDayStat {
+		return teamDayStat{
+			Day:        day.Format(_dayLayout),
+			AmountTime: amounts[day].String(),
+		}
+	})
+}
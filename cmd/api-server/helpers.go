@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func (app *application) backgroundTask(fn func() error) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			err := recover()
+			if err != nil {
+				app.serverLogger().Error("background task panic", "error", fmt.Sprintf("%s", err))
+			}
+		}()
+
+		if err := fn(); err != nil {
+			app.serverLogger().Error("background task failed", "error", err)
+		}
+	}()
+}
+
+// sleepOrQuit waits for the given duration and reports false if the application is shutting down.
+func (app *application) sleepOrQuit(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-app.quit:
+		return false
+	}
+}
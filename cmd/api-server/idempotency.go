@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/protomem/time-tracker/internal/ctxstore"
+	"github.com/protomem/time-tracker/internal/model"
+	"github.com/samber/lo"
+)
+
+const (
+	_idempotencyKeyHeader     = "Idempotency-Key"
+	_idempotentReplayedHeader = "Idempotent-Replayed"
+
+	_maxIdempotencyKeyLength = 255
+	_maxIdempotentBodyBytes  = 1_048_576
+)
+
+// _replayedHeaders are stored with the response, others are set by middlewares for every request.
+var _replayedHeaders = []string{"Content-Type", "Content-Disposition", "Location"}
+
+// idempotentRequestHash reads the body of the request, restores it for the handler
+// and returns the hash of the method, URL and body.
+func idempotentRequestHash(w http.ResponseWriter, r *http.Request) (string, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, _maxIdempotentBodyBytes))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return "", fmt.Errorf("body must not be larger than %d bytes", _maxIdempotentBodyBytes)
+		}
+
+		return "", err
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.RequestURI())
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (app *application) replayIdempotentResponse(w http.ResponseWriter, r *http.Request, key model.IdempotencyKey, hash string) {
+	if key.RequestHash != hash {
+		app.idempotencyKeyReused(w, r)
+		return
+	}
+
+	if !key.Completed() {
+		app.idempotentRequestInProgress(w, r)
+		return
+	}
+
+	var headers http.Header
+	if len(key.ResponseHeaders) != 0 {
+		if err := json.Unmarshal(key.ResponseHeaders, &headers); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	for name, values := range headers {
+		w.Header()[name] = values
+	}
+	w.Header().Set(_idempotentReplayedHeader, "true")
+
+	w.WriteHeader(*key.StatusCode)
+	w.Write(key.ResponseBody)
+}
+
+// idempotentUsers are users whose data the stored response contains, it is deleted when they are erased.
+// Handlers record users created by the request, see recordIdempotentUsers.
+type idempotentUsers struct {
+	ids []model.ID
+}
+
+// of returns recorded users together with the caller and the user of the request path.
+func (u *idempotentUsers) of(r *http.Request) []model.ID {
+	ids := u.ids
+	if p, ok := principalFromRequest(r); ok && p.UserID != nil {
+		ids = append(ids, *p.UserID)
+	}
+	if userID, err := userIDFromRequest(r); err == nil {
+		ids = append(ids, userID)
+	}
+
+	return lo.Uniq(ids)
+}
+
+// recordIdempotentUsers marks users the response refers to, it does nothing for requests without Idempotency-Key.
+func recordIdempotentUsers(r *http.Request, ids ...model.ID) {
+	if users, ok := ctxstore.From[*idempotentUsers](r.Context(), _idempotentUsersKey); ok {
+		users.ids = append(users.ids, ids...)
+	}
+}
+
+func pickHeaders(header http.Header, names []string) http.Header {
+	picked := make(http.Header, len(names))
+	for _, name := range names {
+		if values := header.Values(name); len(values) != 0 {
+			picked[name] = values
+		}
+	}
+
+	return picked
+}
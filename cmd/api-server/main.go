@@ -1,22 +1,51 @@
+//	@securityDefinitions.apikey	BearerAuth
+//	@in							header
+//	@name						Authorization
+//	@description				API key or access token, in the form "Bearer <token>"
+
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"runtime/debug"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/lmittmann/tint"
+	"github.com/protomem/time-tracker/internal/auth"
+	"github.com/protomem/time-tracker/internal/config"
 	"github.com/protomem/time-tracker/internal/database"
-	"github.com/protomem/time-tracker/internal/env"
+	"github.com/protomem/time-tracker/internal/identity"
+	"github.com/protomem/time-tracker/internal/metrics"
+	"github.com/protomem/time-tracker/internal/model"
+	"github.com/protomem/time-tracker/internal/pii"
+	"github.com/protomem/time-tracker/internal/ratelimit"
+	"github.com/protomem/time-tracker/internal/tracing"
 	"github.com/protomem/time-tracker/internal/version"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
-	_cfgFile   = flag.String("cfg", "", "path to config file")
+	_cfgFile   = flag.String("cfg", "", "comma separated paths to config files (.env, .yaml)")
+	_printCfg  = flag.Bool("printConfig", false, "print effective config with redacted secrets and exit")
 	_prettyLog = flag.Bool("prettyLog", false, "pretty log output")
+	_newAPIKey = flag.String("newApiKey", "", "create API key with the given name, print it and exit")
+	_newOrg    = flag.String("newOrganization", "", "create organization with the given name, print its ID and exit")
+	_org       = flag.Uint("organization", model.DefaultOrganization, "organization of the API key created by -newApiKey")
+	_keyPII    = flag.Bool("passportAccess", false, "allow the API key created by -newApiKey to view unmasked passports")
+	_genPIIKey = flag.Bool("genPassportKey", false, "generate passport encryption or index key, print it and exit")
+)
+
+const (
+	_serviceName            = "time-tracker"
+	_reencryptBatchSize     = 500
+	_tracingShutdownTimeout = 5 * time.Second
 )
 
 func init() {
@@ -39,64 +68,175 @@ func main() {
 	}
 }
 
-type config struct {
-	httpHost string
-	httpPort int
-	db       struct {
-		dsn         string
-		automigrate bool
-	}
-	peopleServ struct {
-		serverURL string
-	}
-}
-
 type application struct {
-	config     config
+	config     config.Config
 	db         *database.DB
+	identity   identity.Provider
+	metrics    *metrics.Metrics
+	limiter    ratelimit.Store
+	tracer     trace.Tracer
+	tokens     *auth.TokenIssuer
 	baseLogger *slog.Logger
 	wg         sync.WaitGroup
+	quit       chan struct{}
 }
 
 func run(logger *slog.Logger) error {
-	var cfg config
+	showVersion := flag.Bool("version", false, "display version and exit")
+
+	flag.Parse()
 
+	if *showVersion {
+		fmt.Printf("version: %s\n", version.Get())
+		return nil
+	}
+
+	if *_genPIIKey {
+		key, err := pii.GenerateKey()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("key: %s\n", key)
+		return nil
+	}
+
+	var cfgFiles []string
 	if *_cfgFile != "" {
-		if err := env.Load(*_cfgFile); err != nil {
+		cfgFiles = strings.Split(*_cfgFile, ",")
+	}
+
+	cfg, err := config.Load(cfgFiles...)
+	if err != nil {
+		return err
+	}
+
+	if *_printCfg {
+		return cfg.Print(os.Stdout)
+	}
+
+	protector, err := newPassportProtector(cfg)
+	if err != nil {
+		return err
+	}
+
+	tracerProvider, err := tracing.New(context.Background(), tracing.Config{
+		Enabled:     cfg.Tracing.Enabled,
+		ServiceName: _serviceName,
+		Version:     version.Get(),
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), _tracingShutdownTimeout)
+		defer cancel()
+
+		if err := tracerProvider.Shutdown(ctx); err != nil {
+			logger.Warn("failed to shutdown tracer provider", "error", err)
+		}
+	}()
+
+	db, err := database.New(logger, cfg.DB, tracerProvider)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	db.PII = protector
+
+	reencrypted, err := database.NewUserDAO(logger, db).ReencryptPassports(context.Background(), _reencryptBatchSize)
+	if err != nil {
+		return err
+	}
+	if reencrypted > 0 {
+		logger.Info("passports re-encrypted", "count", reencrypted, "keyId", protector.PrimaryKey())
+	}
+
+	if *_newOrg != "" {
+		organizationID, err := database.NewOrganizationDAO(logger, db).Insert(context.Background(), *_newOrg)
+		if err != nil {
 			return err
 		}
+
+		fmt.Printf("organization id: %d\n", organizationID)
+		return nil
 	}
 
-	cfg.httpHost = env.GetString("HTTP_HOST", "localhost")
-	cfg.httpPort = env.GetInt("HTTP_PORT", 8080)
-	cfg.db.dsn = env.GetString("DB_DSN", "postgres:postgres@localhost:5432/postgres")
-	cfg.db.automigrate = env.GetBool("DB_AUTOMIGRATE", true)
-	cfg.peopleServ.serverURL = env.GetString("PEOPLE_SERVICE_URL", "http://localhost:8081")
+	if *_newAPIKey != "" {
+		if _, err := database.NewOrganizationDAO(logger, db).Get(context.Background(), *_org); err != nil {
+			return err
+		}
 
-	showVersion := flag.Bool("version", false, "display version and exit")
+		ctx := database.WithTenant(context.Background(), *_org)
 
-	flag.Parse()
+		key, secret, err := createAPIKey(ctx, db, logger, *_newAPIKey, nil, *_keyPII)
+		if err != nil {
+			return err
+		}
 
-	if *showVersion {
-		fmt.Printf("version: %s\n", version.Get())
+		fmt.Printf("api key id: %d\napi key: %s\n", key.ID, secret)
 		return nil
 	}
 
-	db, err := database.New(logger, cfg.db.dsn, cfg.db.automigrate)
+	appMetrics := metrics.New(logger)
+	appMetrics.RegisterDB(db.DB.DB, "postgres")
+	appMetrics.RegisterOpenSessions(database.NewSessionDAO(logger, db).CountOpen)
+
+	identityProvider, err := identity.New(
+		logger, cfg.Identity.Provider, cfg.PeopleService.URL, cfg.PeopleService.RequestTimeout,
+		appMetrics, tracerProvider,
+	)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+
+	jwtSecret := []byte(cfg.Auth.JWTSecret)
+	if len(jwtSecret) == 0 {
+		logger.Warn("AUTH_JWT_SECRET is not set, using random secret: access tokens will not survive restart")
+
+		if jwtSecret, err = auth.GenerateSecret(); err != nil {
+			return err
+		}
+	}
 
 	app := &application{
 		config:     cfg,
 		db:         db,
+		identity:   identityProvider,
+		metrics:    appMetrics,
+		limiter:    ratelimit.NewMemoryStore(),
+		tracer:     tracing.Tracer(tracerProvider),
+		tokens:     auth.NewTokenIssuer(jwtSecret, cfg.Auth.AccessTokenTTL),
 		baseLogger: logger,
+		quit:       make(chan struct{}),
 	}
 
 	return app.serveHTTP()
 }
 
+// newPassportProtector builds passport encryption from PASSPORT_ENCRYPTION_KEYS ("<id>:<base64 key>,...")
+// and PASSPORT_INDEX_KEY. The primary key defaults to the latest one.
+func newPassportProtector(cfg config.Config) (*pii.Protector, error) {
+	keys, err := pii.ParseKeys(cfg.Passport.EncryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	indexKey, err := base64.StdEncoding.DecodeString(cfg.Passport.IndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSPORT_INDEX_KEY: %w", err)
+	}
+
+	primary := cfg.Passport.EncryptionKeyID
+	if primary == 0 {
+		primary = pii.LatestKey(keys)
+	}
+
+	return pii.NewProtector(keys, primary, indexKey)
+}
+
 func newJSONLogger() *slog.Logger {
 	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
 }
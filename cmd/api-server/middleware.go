@@ -1,28 +1,123 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/protomem/time-tracker/internal/auth"
 	"github.com/protomem/time-tracker/internal/ctxstore"
+	"github.com/protomem/time-tracker/internal/database"
+	"github.com/protomem/time-tracker/internal/model"
 	"github.com/protomem/time-tracker/internal/response"
 	"github.com/rs/cors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/tomasen/realip"
 )
 
-const _traceIDKey = ctxstore.Key("traceId")
+const (
+	_traceIDKey   = ctxstore.Key("traceId")
+	_requestIDKey = ctxstore.Key("requestId")
+	_principalKey = ctxstore.Key("principal")
+	_accessLogKey = ctxstore.Key("accessLog")
 
-func (app *application) traceID(next http.Handler) http.Handler {
+	_idempotentUsersKey = ctxstore.Key("idempotentUsers")
+)
+
+// accessLogEntry is filled by inner middlewares and handlers, and written by logAccess.
+type accessLogEntry struct {
+	apiKeyID       *model.ID
+	userID         *model.ID
+	organizationID *model.ID
+}
+
+// traceRequest continues the trace from the incoming traceparent header or starts a new one.
+// The server span is named by the route pattern of the handler, its trace ID is used in logs.
+func (app *application) traceRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		tid := genTraceID()
-		ctx := ctxstore.With(r.Context(), _traceIDKey, tid)
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		ctx, span := app.tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		ctx = ctxstore.With(ctx, _traceIDKey, span.SpanContext().TraceID().String())
+
+		mw := response.NewMetricsResponseWriter(w)
+		next.ServeHTTP(mw, r.WithContext(ctx))
+
+		if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+			span.SetName(r.Method + " " + rctx.RoutePattern())
+			span.SetAttributes(semconv.HTTPRoute(rctx.RoutePattern()))
+		}
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(mw.StatusCode))
+		if mw.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(mw.StatusCode))
+		}
+	})
+}
+
+const (
+	_requestIDHeader    = "X-Request-ID"
+	_requestIDMaxLength = 128
+)
+
+// requestID takes the request ID from the X-Request-ID header or generates a new one if the header
+// is missing or invalid, and echoes it in the response.
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rid := r.Header.Get(_requestIDHeader)
+		if !isValidRequestID(rid) {
+			rid = uuid.NewString()
+		}
+
+		w.Header().Set(_requestIDHeader, rid)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("http.request_id", rid))
+
+		ctx := ctxstore.With(r.Context(), _requestIDKey, rid)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// isValidRequestID allows non-empty IDs of limited length with letters, digits and "-_.:" only,
+// so that they are safe to log and to send back.
+func isValidRequestID(rid string) bool {
+	if rid == "" || len(rid) > _requestIDMaxLength {
+		return false
+	}
+
+	for _, c := range rid {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -39,7 +134,8 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 func (app *application) logAccess(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		mw := response.NewMetricsResponseWriter(w)
-		next.ServeHTTP(mw, r)
+		entry := &accessLogEntry{}
+		next.ServeHTTP(mw, r.WithContext(ctxstore.With(r.Context(), _accessLogKey, entry)))
 
 		var (
 			ip     = realip.FromRequest(r)
@@ -47,21 +143,287 @@ func (app *application) logAccess(next http.Handler) http.Handler {
 			url    = r.URL.String()
 			proto  = r.Proto
 			tid    = ctxstore.MustFrom[string](r.Context(), _traceIDKey)
+			rid    = ctxstore.MustFrom[string](r.Context(), _requestIDKey)
 		)
 
-		userAttrs := slog.Group("user", "ip", ip)
-		requestAttrs := slog.Group("request", "method", method, "url", url, "proto", proto, _traceIDKey.String(), tid)
+		userArgs := []any{"ip", ip}
+		if entry.apiKeyID != nil {
+			userArgs = append(userArgs, "apiKeyId", *entry.apiKeyID)
+		}
+		if entry.userID != nil {
+			userArgs = append(userArgs, "userId", *entry.userID)
+		}
+		if entry.organizationID != nil {
+			userArgs = append(userArgs, "organizationId", *entry.organizationID)
+		}
+
+		userAttrs := slog.Group("user", userArgs...)
+		requestAttrs := slog.Group("request", "method", method, "url", url, "proto", proto, _traceIDKey.String(), tid, _requestIDKey.String(), rid)
 		responseAttrs := slog.Group("repsonse", "status", mw.StatusCode, "size", mw.BytesCount)
 
 		app.serverLogger().Info("access", userAttrs, requestAttrs, responseAttrs)
 	})
 }
 
-func (app *application) CORS(next http.Handler) http.Handler {
-	return cors.AllowAll().Handler(next)
+// measureRequests records request metrics by the route pattern, requests not matched by any route
+// are recorded with an empty pattern to keep labels bounded.
+func (app *application) measureRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		mw := response.NewMetricsResponseWriter(w)
+
+		next.ServeHTTP(mw, r)
+
+		var route string
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			route = rctx.RoutePattern()
+		}
+
+		app.metrics.ObserveHTTPRequest(r.Method, route, mw.StatusCode, time.Since(start))
+	})
+}
+
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		baseLogger, _ := app.buildHandlerLoggers(r, "authenticate")
+
+		if !app.config.Auth.Enabled {
+			principal := servicePrincipal(nil, model.DefaultOrganization, true)
+			ctx = ctxstore.With(ctx, _principalKey, principal)
+			ctx = database.WithTenant(ctx, principal.OrganizationID)
+			ctx = database.WithActor(ctx, principal.auditActor(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		w.Header().Add("Vary", "Authorization")
+
+		token, ok := bearerTokenFromRequest(r)
+		if !ok {
+			app.authenticationRequired(w, r)
+			return
+		}
+
+		var (
+			principal principal
+			err       error
+		)
+		if auth.IsAPIKey(token) {
+			principal, err = authenticateAPIKey(ctx, app.db, baseLogger, token)
+		} else {
+			principal, err = authenticateAccessToken(ctx, app.db, baseLogger, app.tokens, token)
+		}
+		if err != nil {
+			if errors.Is(err, model.ErrNotFound) {
+				app.invalidAuthenticationToken(w, r)
+				return
+			}
+
+			app.serverError(w, r, err)
+			return
+		}
+
+		if entry, ok := ctxstore.From[*accessLogEntry](ctx, _accessLogKey); ok {
+			entry.apiKeyID = principal.APIKeyID
+			entry.userID = principal.UserID
+			entry.organizationID = &principal.OrganizationID
+		}
+
+		ctx = ctxstore.With(ctx, _principalKey, principal)
+		ctx = database.WithTenant(ctx, principal.OrganizationID)
+		ctx = database.WithActor(ctx, principal.auditActor(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
 
-func genTraceID() string {
-	id, _ := uuid.NewRandom()
-	return id.String()
+const (
+	_rateLimitLimitHeader     = "RateLimit-Limit"
+	_rateLimitRemainingHeader = "RateLimit-Remaining"
+	_rateLimitResetHeader     = "RateLimit-Reset"
+	_retryAfterHeader         = "Retry-After"
+)
+
+// limitRate limits requests of the authenticated API key or user, unauthenticated requests
+// are limited by the client IP. Read and write requests are limited separately.
+func (app *application) limitRate(next http.Handler) http.Handler {
+	return app.rateLimited(next, clientKey)
+}
+
+// limitRateByIP limits requests by the client IP before authentication,
+// so requests with missing or invalid credentials are throttled too.
+func (app *application) limitRateByIP(next http.Handler) http.Handler {
+	return app.rateLimited(next, func(r *http.Request) string {
+		return "preauth:ip:" + realip.FromRequest(r)
+	})
+}
+
+func (app *application) rateLimited(next http.Handler, key func(r *http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.RateLimit.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		baseLogger, _ := app.buildHandlerLoggers(r, "limitRate")
+
+		class, limit := "write", app.config.RateLimit.Write()
+		if isReadMethod(r.Method) {
+			class, limit = "read", app.config.RateLimit.Read()
+		}
+
+		res, err := app.limiter.Take(ctx, class+":"+key(r), limit)
+		if err != nil {
+			// Failed store should not take the API down.
+			baseLogger.Warn("failed to take rate limit token", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set(_rateLimitLimitHeader, strconv.Itoa(res.Limit))
+		w.Header().Set(_rateLimitRemainingHeader, strconv.Itoa(res.Remaining))
+		w.Header().Set(_rateLimitResetHeader, strconv.Itoa(ceilSeconds(res.Reset)))
+
+		if !res.Allowed {
+			app.rateLimitExceeded(w, r, ceilSeconds(res.RetryAfter))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientKey identifies the caller by the authenticated API key or user, otherwise by the client IP.
+func clientKey(r *http.Request) string {
+	if p, ok := principalFromRequest(r); ok {
+		switch {
+		case p.APIKeyID != nil:
+			return fmt.Sprintf("apikey:%d", *p.APIKeyID)
+		case p.UserID != nil:
+			return fmt.Sprintf("user:%d", *p.UserID)
+		}
+	}
+
+	return "ip:" + realip.FromRequest(r)
+}
+
+func isReadMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// idempotent replays the stored response for retries of POST requests with the same Idempotency-Key,
+// the key is scoped to the caller. Failed requests (5xx) are not stored and can be retried.
+func (app *application) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(_idempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" || app.config.Idempotency.KeyTTL <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		baseLogger, _ := app.buildHandlerLoggers(r, "idempotent")
+
+		if len(key) > _maxIdempotencyKeyLength {
+			app.badRequest(w, r, fmt.Errorf("%s must not be longer than %d characters", _idempotencyKeyHeader, _maxIdempotencyKeyLength))
+			return
+		}
+
+		hash, err := idempotentRequestHash(w, r)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+
+		dao := database.NewIdempotencyKeyDAO(baseLogger, app.db)
+		scope := clientKey(r)
+
+		record, acquired, err := dao.Acquire(ctx, database.AcquireIdempotencyKeyDTO{
+			Scope:       scope,
+			Key:         key,
+			RequestHash: hash,
+			ExpiresAt:   time.Now().Add(app.config.Idempotency.KeyTTL),
+		})
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if !acquired {
+			app.replayIdempotentResponse(w, r, record, hash)
+			return
+		}
+
+		// The response is stored even if the client has gone.
+		ctx = context.WithoutCancel(ctx)
+
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+
+			if err := dao.Release(ctx, scope, key); err != nil {
+				baseLogger.Warn("failed to release idempotency key", "error", err)
+			}
+		}()
+
+		users := &idempotentUsers{}
+		r = r.WithContext(ctxstore.With(r.Context(), _idempotentUsersKey, users))
+
+		rw := response.NewRecordingResponseWriter(w)
+		next.ServeHTTP(rw, r)
+
+		if rw.StatusCode >= http.StatusInternalServerError {
+			return
+		}
+
+		headers, err := json.Marshal(pickHeaders(w.Header(), _replayedHeaders))
+		if err != nil {
+			baseLogger.Warn("failed to store idempotent response", "error", err)
+			return
+		}
+
+		if err := dao.Complete(ctx, scope, key, database.CompleteIdempotencyKeyDTO{
+			StatusCode:      rw.StatusCode,
+			ResponseHeaders: headers,
+			ResponseBody:    rw.Body.Bytes(),
+			Users:           users.of(r),
+		}); err != nil {
+			baseLogger.Warn("failed to store idempotent response", "error", err)
+			return
+		}
+
+		completed = true
+	})
+}
+
+func (app *application) CORS(next http.Handler) http.Handler {
+	return cors.New(cors.Options{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{
+			http.MethodHead,
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+		},
+		AllowedHeaders: []string{"*"},
+		ExposedHeaders: []string{
+			_requestIDHeader,
+			_etagHeader,
+			_idempotentReplayedHeader,
+			_rateLimitLimitHeader,
+			_rateLimitRemainingHeader,
+			_rateLimitResetHeader,
+			_retryAfterHeader,
+		},
+		AllowCredentials: false,
+	}).Handler(next)
 }
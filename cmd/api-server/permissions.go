@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/protomem/time-tracker/internal/database"
+
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+// authorize writes 403 response and reports false if the caller is not allowed by the permission check.
+func (app *application) authorize(w http.ResponseWriter, r *http.Request, allowed func(p principal) bool) bool {
+	p, ok := principalFromRequest(r)
+	if !ok || !allowed(p) {
+		_, handlerLogger := app.buildHandlerLoggers(r, "authorize")
+		handlerLogger.Debug("access denied", "principal", p)
+
+		app.forbidden(w, r)
+		return false
+	}
+
+	return true
+}
+
+// managesUser reports whether the caller is a manager of a team the user is a member of.
+func (app *application) managesUser(r *http.Request, userID model.ID) (bool, error) {
+	p, ok := principalFromRequest(r)
+	if !ok || p.Role != model.RoleManager || p.UserID == nil {
+		return false, nil
+	}
+
+	baseLogger, _ := app.buildHandlerLoggers(r, "authorize")
+
+	return database.NewTeamDAO(baseLogger, app.db).IsManagerOf(r.Context(), *p.UserID, userID)
+}
+
+// managesTeam reports whether the caller is a manager of the team.
+func (app *application) managesTeam(r *http.Request, teamID model.ID) (bool, error) {
+	p, ok := principalFromRequest(r)
+	if !ok || p.Role != model.RoleManager || p.UserID == nil {
+		return false, nil
+	}
+
+	baseLogger, _ := app.buildHandlerLoggers(r, "authorize")
+
+	return database.NewTeamDAO(baseLogger, app.db).IsTeamManager(r.Context(), teamID, *p.UserID)
+}
+
+func (p principal) isAdmin() bool {
+	return p.Role == model.RoleAdmin
+}
+
+func (p principal) isSelf(userID model.ID) bool {
+	return p.UserID != nil && *p.UserID == userID
+}
+
+// Permission checks
+
+func canManageUsers(p principal) bool {
+	return p.isAdmin()
+}
+
+// canListUsers allows to list users, managers see only members of the teams they manage.
+func canListUsers(p principal) bool {
+	return p.isAdmin() || (p.Role == model.RoleManager && p.UserID != nil)
+}
+
+func canManageTeams(p principal) bool {
+	return p.isAdmin()
+}
+
+// canViewTeam allows to read team members and stats.
+func canViewTeam(managed bool) func(p principal) bool {
+	return func(p principal) bool {
+		return p.isAdmin() || (p.Role == model.RoleManager && managed)
+	}
+}
+
+func canViewAudit(p principal) bool {
+	return p.isAdmin()
+}
+
+func canManageAPIKeys(p principal) bool {
+	return p.isAdmin()
+}
+
+// canViewUser allows to read user profile, sessions and stats.
+// Managers can view members of the teams they manage, see app.managesUser.
+func canViewUser(userID model.ID, managed bool) func(p principal) bool {
+	return func(p principal) bool {
+		return p.isAdmin() || (p.Role == model.RoleManager && managed) || p.isSelf(userID)
+	}
+}
+
+// canViewPassport allows to see unmasked passport data,
+// the permission is granted explicitly and is not implied by admin role.
+func canViewPassport(userID model.ID) func(p principal) bool {
+	return func(p principal) bool {
+		return p.CanViewPassports || p.isSelf(userID)
+	}
+}
+
+// canGrantPassportAccess allows to grant the passport permission to users and API keys,
+// only a caller who has it can share it.
+func canGrantPassportAccess(p principal) bool {
+	return p.isAdmin() && p.CanViewPassports
+}
+
+// presentUser masks passport of the user unless the caller can view it.
+func presentUser(r *http.Request, user model.User) model.User {
+	p, ok := principalFromRequest(r)
+	if ok && canViewPassport(user.ID)(p) {
+		return user
+	}
+	return user.WithMaskedPassport()
+}
+
+// canExportUser allows to export all personal data of the user.
+func canExportUser(userID model.ID) func(p principal) bool {
+	return func(p principal) bool {
+		return p.isAdmin() || p.isSelf(userID)
+	}
+}
+
+// canTrackSessions allows to start and stop sessions.
+func canTrackSessions(userID model.ID) func(p principal) bool {
+	return func(p principal) bool {
+		return p.isAdmin() || p.isSelf(userID)
+	}
+}
+
+// canManageSessions allows to revoke login sessions.
+func canManageSessions(userID model.ID) func(p principal) bool {
+	return func(p principal) bool {
+		return p.isAdmin() || p.isSelf(userID)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/protomem/time-tracker/internal/database"
 	"github.com/protomem/time-tracker/internal/model"
+	"github.com/samber/lo"
 )
 
 const _customTimeLayout = "2006-01-02 15:04" // <year>-<month>-<day> <hour>:<minute>
@@ -28,6 +30,24 @@ func taskIDFromRequest(r *http.Request) (model.ID, error) {
 	return model.ID(id), err
 }
 
+func teamIDFromRequest(r *http.Request) (model.ID, error) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "teamId"), 10, 32)
+	return model.ID(id), err
+}
+
+func apiKeyIDFromRequest(r *http.Request) (model.ID, error) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "keyId"), 10, 32)
+	return model.ID(id), err
+}
+
+func bearerTokenFromRequest(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
 func findOptionsFromRequest(r *http.Request) database.FindOptions {
 	page := defaultUintQueryParams(r, "page", _defaultPage)
 	pageSize := defaultUintQueryParams(r, "pageSize", _defaultPageSize)
@@ -43,11 +63,40 @@ func findUserFilterFromRequest(r *http.Request) database.FindUserFilter {
 		Surname:        optionalStringQueryParams(r, "surname"),
 		Patronymic:     optionalStringQueryParams(r, "patronymic"),
 		Address:        optionalStringQueryParams(r, "address"),
-		PassportSerie:  optionalIntQueryParams(r, "passportSerie"),
-		PassportNumber: optionalIntQueryParams(r, "passportNumber"),
+		PassportSerie:  optionalPassportPartQueryParams(r, "passportSerie"),
+		PassportNumber: optionalPassportPartQueryParams(r, "passportNumber"),
 	}
 }
 
+func findAuditFilterFromRequest(r *http.Request) (database.FindAuditFilter, error) {
+	filter := database.FindAuditFilter{
+		Entity: optionalStringQueryParams(r, "entity"),
+	}
+
+	if action := optionalStringQueryParams(r, "action"); action != nil {
+		filter.Action = lo.ToPtr(model.AuditAction(*action))
+	}
+
+	var err error
+	if filter.EntityID, err = optionalIDQueryParams(r, "entityId"); err != nil {
+		return database.FindAuditFilter{}, err
+	}
+	if filter.ActorUser, err = optionalIDQueryParams(r, "actorUserId"); err != nil {
+		return database.FindAuditFilter{}, err
+	}
+	if filter.ActorAPIKey, err = optionalIDQueryParams(r, "actorApiKeyId"); err != nil {
+		return database.FindAuditFilter{}, err
+	}
+
+	opts, err := sessionTimelineOptionsFromRequest(r)
+	if err != nil {
+		return database.FindAuditFilter{}, err
+	}
+	filter.After, filter.Before = opts.After, opts.Before
+
+	return filter, nil
+}
+
 func sessionTimelineOptionsFromRequest(r *http.Request) (database.SessionTimelineOptions, error) {
 	opts := database.SessionTimelineOptions{}
 
@@ -96,6 +145,21 @@ func defaultUintQueryParams(r *http.Request, key string, def uint64) uint64 {
 	return uintVal
 }
 
+func defaultBoolQueryParams(r *http.Request, key string, def bool) bool {
+	val, ok := r.URL.Query().Get(key), r.URL.Query().Has(key)
+	if !ok {
+		return def
+	}
+	if val == "" {
+		return true
+	}
+	boolVal, err := strconv.ParseBool(val)
+	if err != nil {
+		return def
+	}
+	return boolVal
+}
+
 func optionalStringQueryParams(r *http.Request, key string) *string {
 	ref := new(string)
 	val, ok := r.URL.Query().Get(key), r.URL.Query().Has(key)
@@ -106,16 +170,23 @@ func optionalStringQueryParams(r *http.Request, key string) *string {
 	return ref
 }
 
-func optionalIntQueryParams(r *http.Request, key string) *int {
-	ref := new(int)
+func optionalIDQueryParams(r *http.Request, key string) (*model.ID, error) {
 	val, ok := r.URL.Query().Get(key), r.URL.Query().Has(key)
 	if !ok {
-		return nil
+		return nil, nil
 	}
-	intVal, err := strconv.Atoi(val)
+	id, err := strconv.ParseUint(val, 10, 32)
 	if err != nil {
+		return nil, fmt.Errorf("invalid %s query param: %w", key, err)
+	}
+	return lo.ToPtr(model.ID(id)), nil
+}
+
+func optionalPassportPartQueryParams(r *http.Request, key string) *string {
+	val := optionalStringQueryParams(r, key)
+	if val == nil {
 		return nil
 	}
-	*ref = intVal
-	return ref
+	*val = model.NormalizePassportPart(*val)
+	return val
 }
@@ -13,19 +13,28 @@ func (app *application) confiureSwagger() {
 	docs.SwaggerInfo.Title = "Time Tracker"
 	docs.SwaggerInfo.Description = "Web API - Time Tracker"
 	docs.SwaggerInfo.Version = "1.0"
-	docs.SwaggerInfo.Host = fmtHTTPAddr("localhost", app.config.httpPort)
+	docs.SwaggerInfo.Host = fmtHTTPAddr("localhost", app.config.HTTP.Port)
 	docs.SwaggerInfo.BasePath = "/api/v1"
 	docs.SwaggerInfo.Schemes = []string{"http"}
 }
 
+// metricsRoutes are served by the metrics listener, see app.serveMetrics.
+func (app *application) metricsRoutes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", app.metrics.Handler())
+	return mux
+}
+
 func (app *application) routes() http.Handler {
 	mux := chi.NewRouter()
 
 	mux.NotFound(app.notFound)
 	mux.MethodNotAllowed(app.methodNotAllowed)
 
-	mux.Use(app.traceID)
+	mux.Use(app.traceRequest)
+	mux.Use(app.requestID)
 	mux.Use(app.logAccess)
+	mux.Use(app.measureRequests)
 	mux.Use(app.recoverPanic)
 
 	mux.Use(app.CORS)
@@ -34,20 +43,67 @@ func (app *application) routes() http.Handler {
 
 	mux.Get("/api/v1/status", app.handleStatus)
 
-	mux.Get("/api/v1/users", app.handleFindUsers)
-	mux.Post("/api/v1/users", app.handleAddUser)
-	mux.Put("/api/v1/users/{userId}", app.handleUpdateUser)
-	mux.Delete("/api/v1/users/{userId}", app.handleDeleteUser)
-
-	mux.Get("/api/v1/users/{userId}/stats", app.handleUserStats)
-
-	mux.Get("/api/v1/sessions/{userId}", app.handleFindSessions)
-	mux.Post("/api/v1/sessions/{userId}/{taskId}", app.handleSessionStart)
-	mux.Delete("/api/v1/sessions/{userId}/{taskId}", app.handleSessionStop)
+	mux.Get("/livez", app.handleLivez)
+	mux.Get("/readyz", app.handleReadyz)
+
+	mux.Group(func(mux chi.Router) {
+		mux.Use(app.limitRate)
+
+		mux.Post("/api/v1/auth/login", app.handleLogin)
+		mux.Post("/api/v1/auth/refresh", app.handleRefreshTokens)
+		mux.Post("/api/v1/auth/logout", app.handleLogout)
+	})
+
+	mux.Group(func(mux chi.Router) {
+		mux.Use(app.limitRateByIP)
+		mux.Use(app.authenticate)
+		mux.Use(app.limitRate)
+		mux.Use(app.idempotent)
+
+		mux.Get("/api/v1/me", app.handleGetMe)
+		mux.Get("/api/v1/me/sessions", app.handleFindMySessions)
+		mux.Get("/api/v1/me/stats", app.handleMyStats)
+		mux.Get("/api/v1/me/export", app.handleExportMe)
+		mux.Post("/api/v1/me/tasks/{taskId}/start", app.handleMySessionStart)
+		mux.Post("/api/v1/me/tasks/{taskId}/stop", app.handleMySessionStop)
+
+		mux.Get("/api/v1/apikeys", app.handleFindAPIKeys)
+		mux.Post("/api/v1/apikeys", app.handleCreateAPIKey)
+		mux.Delete("/api/v1/apikeys/{keyId}", app.handleRevokeAPIKey)
+
+		mux.Get("/api/v1/users", app.handleFindUsers)
+		mux.Post("/api/v1/users", app.handleAddUser)
+		mux.Post("/api/v1/users/import", app.handleImportUsers)
+		mux.Get("/api/v1/users/{userId}", app.handleGetUser)
+		mux.Put("/api/v1/users/{userId}", app.handleUpdateUser)
+		mux.Patch("/api/v1/users/{userId}", app.handlePatchUser)
+		mux.Delete("/api/v1/users/{userId}", app.handleDeleteUser)
+
+		mux.Post("/api/v1/users/{userId}/refresh", app.handleRefreshUser)
+		mux.Post("/api/v1/users/{userId}/login-codes", app.handleCreateLoginCode)
+		mux.Delete("/api/v1/users/{userId}/auth-sessions", app.handleRevokeUserAuthSessions)
+		mux.Get("/api/v1/users/{userId}/stats", app.handleUserStats)
+		mux.Get("/api/v1/users/{userId}/export", app.handleExportUser)
+		mux.Post("/api/v1/users/{userId}/erase", app.handleEraseUser)
+
+		mux.Get("/api/v1/teams", app.handleFindTeams)
+		mux.Post("/api/v1/teams", app.handleCreateTeam)
+		mux.Delete("/api/v1/teams/{teamId}", app.handleDeleteTeam)
+		mux.Get("/api/v1/teams/{teamId}/members", app.handleFindTeamMembers)
+		mux.Put("/api/v1/teams/{teamId}/members/{userId}", app.handleSetTeamMember)
+		mux.Delete("/api/v1/teams/{teamId}/members/{userId}", app.handleRemoveTeamMember)
+		mux.Get("/api/v1/teams/{teamId}/stats", app.handleTeamStats)
+
+		mux.Get("/api/v1/sessions/{userId}", app.handleFindSessions)
+		mux.Post("/api/v1/sessions/{userId}/{taskId}", app.handleSessionStart)
+		mux.Delete("/api/v1/sessions/{userId}/{taskId}", app.handleSessionStop)
+
+		mux.Get("/api/v1/audit", app.handleFindAuditEntries)
+	})
 
 	mux.Get("/swagger/*", httpSwagger.Handler(
 		httpSwagger.URL(
-			"http://"+fmtHTTPAddr("localhost", app.config.httpPort)+"/swagger/doc.json",
+			"http://"+fmtHTTPAddr("localhost", app.config.HTTP.Port)+"/swagger/doc.json",
 		), // The url pointing to API definition
 	))
 
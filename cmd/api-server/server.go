@@ -10,28 +10,20 @@ import (
 	"os/signal"
 	"strconv"
 	"syscall"
-	"time"
 
 	"github.com/protomem/time-tracker/internal/ctxstore"
 )
 
-const (
-	_defaultIdleTimeout    = time.Minute
-	_defaultReadTimeout    = 5 * time.Second
-	_defaultWriteTimeout   = 10 * time.Second
-	_defaultShutdownPeriod = 30 * time.Second
-)
-
 func (app *application) serveHTTP() error {
 	app.confiureSwagger()
 
 	srv := &http.Server{
-		Addr:         fmtHTTPAddr(app.config.httpHost, app.config.httpPort),
+		Addr:         fmtHTTPAddr(app.config.HTTP.Host, app.config.HTTP.Port),
 		Handler:      app.routes(),
 		ErrorLog:     slog.NewLogLogger(app.baseLogger.Handler(), slog.LevelWarn),
-		IdleTimeout:  _defaultIdleTimeout,
-		ReadTimeout:  _defaultReadTimeout,
-		WriteTimeout: _defaultWriteTimeout,
+		IdleTimeout:  app.config.HTTP.IdleTimeout,
+		ReadTimeout:  app.config.HTTP.ReadTimeout,
+		WriteTimeout: app.config.HTTP.WriteTimeout,
 	}
 
 	shutdownErrorChan := make(chan error)
@@ -41,12 +33,20 @@ func (app *application) serveHTTP() error {
 		signal.Notify(quitChan, syscall.SIGINT, syscall.SIGTERM)
 		<-quitChan
 
-		ctx, cancel := context.WithTimeout(context.Background(), _defaultShutdownPeriod)
+		close(app.quit)
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.HTTP.ShutdownPeriod)
 		defer cancel()
 
 		shutdownErrorChan <- srv.Shutdown(ctx)
 	}()
 
+	app.serveMetrics()
+
+	app.startUserSyncJob()
+	app.startRetentionJob()
+	app.startIdempotencyCleanupJob()
+
 	app.serverLogger().Info("starting server", slog.Group("server", "addr", srv.Addr))
 
 	err := srv.ListenAndServe()
@@ -65,6 +65,44 @@ func (app *application) serveHTTP() error {
 	return nil
 }
 
+// serveMetrics starts the metrics listener, it is stopped together with the API server.
+func (app *application) serveMetrics() {
+	if !app.config.Metrics.Enabled {
+		return
+	}
+
+	srv := &http.Server{
+		Addr:         fmtHTTPAddr(app.config.Metrics.Host, app.config.Metrics.Port),
+		Handler:      app.metricsRoutes(),
+		ErrorLog:     slog.NewLogLogger(app.baseLogger.Handler(), slog.LevelWarn),
+		IdleTimeout:  app.config.HTTP.IdleTimeout,
+		ReadTimeout:  app.config.HTTP.ReadTimeout,
+		WriteTimeout: app.config.HTTP.WriteTimeout,
+	}
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+
+		<-app.quit
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.HTTP.ShutdownPeriod)
+		defer cancel()
+
+		if err := srv.Shutdown(ctx); err != nil {
+			app.serverLogger().Warn("failed to stop metrics server", "error", err)
+		}
+	}()
+
+	go func() {
+		app.serverLogger().Info("starting metrics server", slog.Group("server", "addr", srv.Addr))
+
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			app.serverLogger().Error("metrics server failed", "error", err)
+		}
+	}()
+}
+
 func (app *application) serverLogger(args ...any) *slog.Logger {
 	args = append(args, "module", "server")
 	return app.baseLogger.With(args...)
@@ -72,7 +110,8 @@ func (app *application) serverLogger(args ...any) *slog.Logger {
 
 func (app *application) buildHandlerLoggers(r *http.Request, handlerName string) (base *slog.Logger, handler *slog.Logger) {
 	tid := ctxstore.MustFrom[string](r.Context(), _traceIDKey)
-	baseArgs := []any{_traceIDKey.String(), tid}
+	rid := ctxstore.MustFrom[string](r.Context(), _requestIDKey)
+	baseArgs := []any{_traceIDKey.String(), tid, _requestIDKey.String(), rid}
 	handlerArgs := append(baseArgs, "handler", handlerName)
 	return app.baseLogger.With(baseArgs...), app.serverLogger(handlerArgs...)
 }
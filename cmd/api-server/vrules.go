@@ -1,7 +1,10 @@
 package main
 
 import (
+	"fmt"
+
 	"github.com/protomem/time-tracker/internal/database"
+	"github.com/protomem/time-tracker/internal/model"
 	"github.com/protomem/time-tracker/internal/validator"
 )
 
@@ -28,7 +31,55 @@ func validateFindUserFilter(v *validator.Validator, filter database.FindUserFilt
 	}
 }
 
-func validateRequestUpdateUser(v *validator.Validator, request requestUpdateUser) {
+func validateRequestReplaceUser(v *validator.Validator, request requestReplaceUser) {
+	validateUserName(v, request.Name)
+	validateUserSurname(v, request.Surname)
+	if request.Patronymic != nil {
+		validateUserPatronymic(v, *request.Patronymic)
+	}
+	validatePassport(v, model.Passport{Serie: request.PassportSerie, Number: request.PassportNumber})
+	validateAddress(v, request.Address)
+	validateRole(v, request.Role)
+}
+
+// validateRequestPatchUser allows null only for nullable fields, null of others would remove them.
+func validateRequestPatchUser(v *validator.Validator, request requestPatchUser) {
+	v.CheckField(!request.Name.Null, "name", "cannot be null")
+	v.CheckField(!request.Surname.Null, "surname", "cannot be null")
+	v.CheckField(!request.PassportSerie.Null, "passportSerie", "cannot be null")
+	v.CheckField(!request.PassportNumber.Null, "passportNumber", "cannot be null")
+	v.CheckField(!request.Address.Null, "address", "cannot be null")
+	v.CheckField(!request.Role.Null, "role", "cannot be null")
+	v.CheckField(!request.CanViewPassports.Null, "canViewPassports", "cannot be null")
+
+	if name := request.Name.Ptr(); name != nil {
+		validateUserName(v, *name)
+	}
+	if surname := request.Surname.Ptr(); surname != nil {
+		validateUserSurname(v, *surname)
+	}
+	if patronymic := request.Patronymic.Ptr(); patronymic != nil {
+		validateUserPatronymic(v, *patronymic)
+	}
+	if serie := request.PassportSerie.Ptr(); serie != nil {
+		validatePassportSerie(v, *serie)
+	}
+	if number := request.PassportNumber.Ptr(); number != nil {
+		validatePassportNumber(v, *number)
+	}
+	if address := request.Address.Ptr(); address != nil {
+		validateAddress(v, *address)
+	}
+	if role := request.Role.Ptr(); role != nil {
+		validateRole(v, *role)
+	}
+}
+
+func validateRequestAddUserPerson(v *validator.Validator, request requestAddUser) {
+	v.CheckField(request.Name != nil, "name", "must be provided")
+	v.CheckField(request.Surname != nil, "surname", "must be provided")
+	v.CheckField(request.Address != nil, "address", "must be provided")
+
 	if request.Name != nil {
 		validateUserName(v, *request.Name)
 	}
@@ -38,17 +89,18 @@ func validateRequestUpdateUser(v *validator.Validator, request requestUpdateUser
 	if request.Patronymic != nil {
 		validateUserPatronymic(v, *request.Patronymic)
 	}
-	if request.PassportSerie != nil {
-		validatePassportSerie(v, *request.PassportSerie)
-	}
-	if request.PassportNumber != nil {
-		validatePassportNumber(v, *request.PassportNumber)
-	}
 	if request.Address != nil {
 		validateAddress(v, *request.Address)
 	}
 }
 
+func validateRequestAddUserNoPerson(v *validator.Validator, request requestAddUser) {
+	v.CheckField(request.Name == nil, "name", "must not be provided, filled by identity provider")
+	v.CheckField(request.Surname == nil, "surname", "must not be provided, filled by identity provider")
+	v.CheckField(request.Patronymic == nil, "patronymic", "must not be provided, filled by identity provider")
+	v.CheckField(request.Address == nil, "address", "must not be provided, filled by identity provider")
+}
+
 func validateUserName(v *validator.Validator, userName string) {
 	v.CheckField(validator.NotBlank(userName), "name", "cannot be blank")
 }
@@ -61,22 +113,45 @@ func validateUserPatronymic(v *validator.Validator, userPatronymic string) {
 	v.CheckField(validator.NotBlank(userPatronymic), "patronymic", "cannot be blank")
 }
 
-func validatePassportSerie(v *validator.Validator, passportSerie int) {
+func validatePassport(v *validator.Validator, passport model.Passport) {
+	validatePassportSerie(v, passport.Serie)
+	validatePassportNumber(v, passport.Number)
+}
+
+func validatePassportSerie(v *validator.Validator, passportSerie string) {
 	v.CheckField(
-		passportSerie >= 0,
+		validator.DigitsInNumber(passportSerie, model.PassportSerieDigits),
 		"passportSerie",
-		"must not negative number",
+		fmt.Sprintf("must be %d digits", model.PassportSerieDigits),
 	)
 }
 
-func validatePassportNumber(v *validator.Validator, passportNumber int) {
+func validatePassportNumber(v *validator.Validator, passportNumber string) {
 	v.CheckField(
-		passportNumber >= 0,
+		validator.DigitsInNumber(passportNumber, model.PassportNumberDigits),
 		"passportNumber",
-		"must not negative number",
+		fmt.Sprintf("must be %d digits", model.PassportNumberDigits),
 	)
 }
 
 func validateAddress(v *validator.Validator, address string) {
 	v.CheckField(validator.NotBlank(address), "address", "cannot be blank")
 }
+
+func validateRole(v *validator.Validator, role model.Role) {
+	v.CheckField(validator.In(role, model.Roles...), "role", "must be one of admin, manager, employee")
+}
+
+func validateAPIKeyName(v *validator.Validator, name string) {
+	v.CheckField(validator.NotBlank(name), "name", "cannot be blank")
+	v.CheckField(validator.MaxRunes(name, 100), "name", "must not be more than 100 characters")
+}
+
+func validateTeamName(v *validator.Validator, name string) {
+	v.CheckField(validator.NotBlank(name), "name", "cannot be blank")
+	v.CheckField(validator.MaxRunes(name, 100), "name", "must not be more than 100 characters")
+}
+
+func validateTeamRole(v *validator.Validator, role model.TeamRole) {
+	v.CheckField(validator.In(role, model.TeamRoles...), "role", "must be one of member, manager")
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/protomem/time-tracker/internal/database"
+	"github.com/protomem/time-tracker/internal/identity"
+	"github.com/protomem/time-tracker/internal/model"
+	"github.com/samber/lo"
+)
+
+const (
+	_enrichMaxAttempts  = 5
+	_enrichRetryBackoff = time.Second
+
+	_retentionBatchSize = 500
+
+	_idempotencyCleanupInterval = time.Hour
+)
+
+// enrichUserInBackground keeps values of the request context (e.g. tenant), but not its cancellation.
+func (app *application) enrichUserInBackground(
+	ctx context.Context, logger *slog.Logger,
+	userID model.ID, passport model.Passport,
+) {
+	ctx = context.WithoutCancel(ctx)
+	logger = logger.With("worker", "enrichUser", "userId", userID)
+
+	app.backgroundTask(func() error {
+		return app.enrichUser(ctx, logger, userID, passport)
+	})
+}
+
+// enrichUser fetches personal data from the people service with exponential backoff
+// and marks the user as completed or failed.
+func (app *application) enrichUser(ctx context.Context, logger *slog.Logger, userID model.ID, passport model.Passport) error {
+	dao := database.NewUserDAO(logger, app.db)
+
+	backoff := _enrichRetryBackoff
+	for attempt := 1; attempt <= _enrichMaxAttempts; attempt++ {
+		lookupCtx, cancel := context.WithTimeout(ctx, app.config.PeopleService.RequestTimeout)
+		person, err := lookupPerson(lookupCtx, app.identity, logger, passport)
+		cancel()
+
+		if err == nil {
+			status := model.EnrichmentCompleted
+			dto := database.UpdateUserDTO{
+				Name:             lo.ToPtr(person.Name),
+				Surname:          lo.ToPtr(person.Surname),
+				Patronymic:       person.Patronymic,
+				Address:          lo.ToPtr(person.Address),
+				EnrichmentStatus: &status,
+			}
+
+			logger.Debug("user enriched", "attempt", attempt)
+
+			return dao.Update(ctx, userID, dto)
+		}
+
+		if errors.Is(err, model.ErrNotFound) || errors.Is(err, identity.ErrLookupUnsupported) {
+			logger.Warn("person not found", "attempt", attempt, "error", err)
+			break
+		}
+
+		logger.Warn("failed to lookup person", "attempt", attempt, "error", err)
+
+		if attempt == _enrichMaxAttempts {
+			break
+		}
+
+		if !app.sleepOrQuit(backoff) {
+			logger.Warn("enrichment interrupted by shutdown", "attempt", attempt)
+			break
+		}
+		backoff *= 2
+	}
+
+	status := model.EnrichmentFailed
+	return dao.Update(ctx, userID, database.UpdateUserDTO{EnrichmentStatus: &status})
+}
+
+func (app *application) startUserSyncJob() {
+	logger := app.baseLogger.With("worker", "syncUsers")
+
+	if app.config.PeopleService.SyncInterval <= 0 || app.identity.Manual() {
+		logger.Info("user sync job disabled")
+		return
+	}
+
+	app.backgroundTask(func() error {
+		for app.sleepOrQuit(app.config.PeopleService.SyncInterval) {
+			if err := app.syncAllUsers(logger); err != nil {
+				logger.Error("failed to sync users", "error", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// syncAllUsers re-queries the identity provider for every user of every organization in batches,
+// limiting the number of requests per second.
+func (app *application) syncAllUsers(logger *slog.Logger) error {
+	limiter := time.NewTicker(time.Second / time.Duration(max(app.config.PeopleService.SyncRate, 1)))
+	defer limiter.Stop()
+
+	opts := database.FindOptions{Limit: uint64(max(app.config.PeopleService.SyncBatchSize, 1))}
+	countSynced, countChanged := 0, 0
+
+	logger.Info("start user sync")
+
+	for {
+		organizations, err := database.NewOrganizationDAO(logger, app.db).Find(context.Background(), opts)
+		if err != nil {
+			return err
+		}
+
+		for _, organization := range organizations {
+			select {
+			case <-app.quit:
+				return nil
+			default:
+			}
+
+			ctx := database.WithTenant(context.Background(), organization.ID)
+
+			synced, changed, err := app.syncOrganizationUsers(ctx, logger.With("organizationId", organization.ID), limiter)
+			countSynced, countChanged = countSynced+synced, countChanged+changed
+			if err != nil {
+				return err
+			}
+		}
+
+		if uint64(len(organizations)) < opts.Limit {
+			break
+		}
+		opts.Offset += opts.Limit
+	}
+
+	logger.Info("finish user sync", "countSynced", countSynced, "countChanged", countChanged)
+
+	return nil
+}
+
+// syncOrganizationUsers syncs users of the tenant from the context,
+// it stops without error on shutdown.
+func (app *application) syncOrganizationUsers(
+	ctx context.Context, logger *slog.Logger,
+	limiter *time.Ticker,
+) (int, int, error) {
+	dao := database.NewUserDAO(logger, app.db)
+
+	opts := database.FindOptions{Limit: uint64(max(app.config.PeopleService.SyncBatchSize, 1))}
+	countSynced, countChanged := 0, 0
+
+	for {
+		users, err := dao.Find(ctx, database.FindUserFilter{Erased: lo.ToPtr(false)}, opts)
+		if err != nil {
+			return countSynced, countChanged, err
+		}
+
+		for _, user := range users {
+			select {
+			case <-limiter.C:
+			case <-app.quit:
+				logger.Warn("user sync interrupted by shutdown", "countSynced", countSynced)
+				return countSynced, countChanged, nil
+			}
+
+			syncCtx, cancel := context.WithTimeout(ctx, app.config.PeopleService.RequestTimeout)
+			changes, err := syncUser(syncCtx, app.db, logger, app.identity, user)
+			cancel()
+			if err != nil {
+				logger.Warn("failed to sync user", "userId", user.ID, "error", err)
+				continue
+			}
+
+			countSynced++
+			if len(changes) != 0 {
+				countChanged++
+			}
+		}
+
+		if uint64(len(users)) < opts.Limit {
+			break
+		}
+		opts.Offset += opts.Limit
+	}
+
+	return countSynced, countChanged, nil
+}
+
+// syncUser refreshes user personal data from the identity provider and records changed fields.
+func syncUser(
+	ctx context.Context, db *database.DB, logger *slog.Logger, provider identity.Provider,
+	user model.User,
+) ([]model.UserChange, error) {
+	if user.ErasedAt != nil {
+		return []model.UserChange{}, model.NewError("user", model.ErrErased)
+	}
+
+	person, err := lookupPerson(ctx, provider, logger, user.Passport())
+	if err != nil {
+		return []model.UserChange{}, err
+	}
+
+	var (
+		dto     database.UpdateUserDTO
+		changes []database.InsertUserChangeDTO
+	)
+
+	diff := func(field string, oldValue *string, newValue string) *string {
+		if oldValue != nil && *oldValue == newValue {
+			return nil
+		}
+		changes = append(changes, database.InsertUserChangeDTO{
+			Field:    field,
+			OldValue: oldValue,
+			NewValue: lo.ToPtr(newValue),
+		})
+		return lo.ToPtr(newValue)
+	}
+
+	dto.Name = diff("name", lo.ToPtr(user.Name), person.Name)
+	dto.Surname = diff("surname", lo.ToPtr(user.Surname), person.Surname)
+	if person.Patronymic != nil {
+		dto.Patronymic = diff("patronymic", user.Patronymic, *person.Patronymic)
+	} else if user.Patronymic != nil {
+		// Patronymic dropped by the provider is cleared.
+		dto.ClearPatronymic = true
+		changes = append(changes, database.InsertUserChangeDTO{
+			Field:    "patronymic",
+			OldValue: user.Patronymic,
+			NewValue: nil,
+		})
+	}
+	dto.Address = diff("address", lo.ToPtr(user.Address), person.Address)
+
+	if user.EnrichmentStatus != model.EnrichmentCompleted {
+		dto.EnrichmentStatus = lo.ToPtr(model.EnrichmentCompleted)
+	}
+
+	if len(changes) == 0 && dto.EnrichmentStatus == nil {
+		logger.Debug("user up to date", "userId", user.ID)
+		return []model.UserChange{}, nil
+	}
+
+	recorded, err := database.NewUserDAO(logger, db).UpdateWithChanges(ctx, user.ID, dto, changes)
+	if err != nil {
+		return []model.UserChange{}, err
+	}
+
+	logger.Debug("user synced", "userId", user.ID, "countChanges", len(recorded))
+
+	return recorded, nil
+}
+
+func (app *application) startRetentionJob() {
+	logger := app.baseLogger.With("worker", "retention")
+
+	if app.config.Retention.SessionsMonths <= 0 || app.config.Retention.Interval <= 0 {
+		logger.Info("retention job disabled")
+		return
+	}
+
+	app.backgroundTask(func() error {
+		for {
+			if err := app.archiveOldSessions(logger); err != nil {
+				logger.Error("failed to archive sessions", "error", err)
+			}
+
+			if !app.sleepOrQuit(app.config.Retention.Interval) {
+				return nil
+			}
+		}
+	})
+}
+
+// archiveOldSessions moves sessions of whole months older than the retention period into monthly summaries.
+func (app *application) archiveOldSessions(logger *slog.Logger) error {
+	cutoff := model.MonthStart(time.Now()).AddDate(0, -app.config.Retention.SessionsMonths, 0)
+
+	logger.Info("start session archiving", "cutoff", cutoff)
+
+	count, err := database.NewSessionSummaryDAO(logger, app.db).Archive(context.Background(), cutoff, _retentionBatchSize)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("finish session archiving", "countArchived", count)
+
+	return nil
+}
+
+func (app *application) startIdempotencyCleanupJob() {
+	logger := app.baseLogger.With("worker", "idempotencyCleanup")
+
+	if app.config.Idempotency.KeyTTL <= 0 {
+		logger.Info("idempotency cleanup job disabled")
+		return
+	}
+
+	app.backgroundTask(func() error {
+		for app.sleepOrQuit(_idempotencyCleanupInterval) {
+			count, err := database.NewIdempotencyKeyDAO(logger, app.db).DeleteExpired(context.Background())
+			if err != nil {
+				logger.Error("failed to delete expired idempotency keys", "error", err)
+				continue
+			}
+
+			logger.Debug("expired idempotency keys deleted", "countDeleted", count)
+		}
+
+		return nil
+	})
+}
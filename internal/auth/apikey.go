@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	_apiKeyPrefix        = "tt_"
+	_apiKeyRandomBytes   = 32
+	_apiKeyVisiblePrefix = 8
+)
+
+// GenerateAPIKey returns a new random API key and its public prefix used to identify the key in listings.
+func GenerateAPIKey() (key string, prefix string, err error) {
+	b := make([]byte, _apiKeyRandomBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	key = _apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	prefix = key[:len(_apiKeyPrefix)+_apiKeyVisiblePrefix]
+
+	return key, prefix, nil
+}
+
+// HashToken returns SHA-256 hex digest of the API key, refresh token or login code.
+// Tokens have high entropy, so a fast hash is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, _apiKeyPrefix)
+}
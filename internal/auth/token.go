@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const _issuer = "time-tracker"
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type AccessClaims struct {
+	UserID    uint
+	SessionID uint
+	ExpiresAt time.Time
+}
+
+type jwtClaims struct {
+	SessionID uint `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// TokenIssuer signs and verifies short-lived HS256 access tokens.
+type TokenIssuer struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func NewTokenIssuer(secret []byte, ttl time.Duration) *TokenIssuer {
+	return &TokenIssuer{secret: secret, ttl: ttl}
+}
+
+func (ti *TokenIssuer) TTL() time.Duration {
+	return ti.ttl
+}
+
+func (ti *TokenIssuer) Issue(userID, sessionID uint) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ti.ttl)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaims{
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    _issuer,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+
+	signed, err := token.SignedString(ti.secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signed, expiresAt, nil
+}
+
+func (ti *TokenIssuer) Parse(token string) (AccessClaims, error) {
+	var claims jwtClaims
+
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return ti.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(_issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return AccessClaims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 32)
+	if err != nil || claims.SessionID == 0 {
+		return AccessClaims{}, ErrInvalidToken
+	}
+
+	return AccessClaims{
+		UserID:    uint(userID),
+		SessionID: claims.SessionID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// GenerateRefreshToken returns a new opaque random refresh token.
+func GenerateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// GenerateLoginCode returns a new one-time login code, short enough to be typed by a user.
+func GenerateLoginCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// GenerateSecret returns a random secret for signing tokens.
+func GenerateSecret() ([]byte, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
@@ -0,0 +1,210 @@
+// Package config loads the service configuration from environment variables,
+// .env and YAML files, and validates it up front.
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/protomem/time-tracker/internal/identity"
+	"github.com/protomem/time-tracker/internal/pii"
+	"github.com/protomem/time-tracker/internal/ratelimit"
+)
+
+// Config is the service configuration. Every field is set by the environment variable from the env tag,
+// in YAML files it is set by the path of yaml tags, e.g. http.readTimeout.
+type Config struct {
+	HTTP struct {
+		Host           string        `env:"HTTP_HOST" yaml:"host" default:"localhost"`
+		Port           int           `env:"HTTP_PORT" yaml:"port" default:"8080"`
+		ReadTimeout    time.Duration `env:"HTTP_READ_TIMEOUT" yaml:"readTimeout" default:"5s"`
+		WriteTimeout   time.Duration `env:"HTTP_WRITE_TIMEOUT" yaml:"writeTimeout" default:"10s"`
+		IdleTimeout    time.Duration `env:"HTTP_IDLE_TIMEOUT" yaml:"idleTimeout" default:"1m"`
+		ShutdownPeriod time.Duration `env:"HTTP_SHUTDOWN_PERIOD" yaml:"shutdownPeriod" default:"30s"`
+	} `yaml:"http"`
+
+	DB DB `yaml:"db"`
+
+	Auth struct {
+		Enabled         bool          `env:"AUTH_ENABLED" yaml:"enabled" default:"true"`
+		JWTSecret       string        `env:"AUTH_JWT_SECRET" yaml:"jwtSecret" secret:"true"`
+		AccessTokenTTL  time.Duration `env:"AUTH_ACCESS_TOKEN_TTL" yaml:"accessTokenTTL" default:"15m"`
+		RefreshTokenTTL time.Duration `env:"AUTH_REFRESH_TOKEN_TTL" yaml:"refreshTokenTTL" default:"720h"`
+		LoginCodeTTL    time.Duration `env:"AUTH_LOGIN_CODE_TTL" yaml:"loginCodeTTL" default:"10m"`
+	} `yaml:"auth"`
+
+	Identity struct {
+		Provider string `env:"IDENTITY_PROVIDER" yaml:"provider" default:"people_service"`
+	} `yaml:"identity"`
+
+	Passport struct {
+		EncryptionKeys  string `env:"PASSPORT_ENCRYPTION_KEYS" yaml:"encryptionKeys" secret:"true"`
+		EncryptionKeyID uint   `env:"PASSPORT_ENCRYPTION_KEY_ID" yaml:"encryptionKeyId" default:"0"`
+		IndexKey        string `env:"PASSPORT_INDEX_KEY" yaml:"indexKey" secret:"true"`
+	} `yaml:"passport"`
+
+	Tracing struct {
+		Enabled     bool    `env:"TRACING_ENABLED" yaml:"enabled" default:"false"`
+		SampleRatio float64 `env:"TRACING_SAMPLE_RATIO" yaml:"sampleRatio" default:"1"`
+	} `yaml:"tracing"`
+
+	// Metrics are served on a separate listener, so that they are not exposed with the API.
+	Metrics struct {
+		Enabled bool   `env:"METRICS_ENABLED" yaml:"enabled" default:"true"`
+		Host    string `env:"METRICS_HOST" yaml:"host" default:"localhost"`
+		Port    int    `env:"METRICS_PORT" yaml:"port" default:"9090"`
+	} `yaml:"metrics"`
+
+	Health struct {
+		CheckPeopleService bool `env:"READINESS_CHECK_PEOPLE_SERVICE" yaml:"checkPeopleService" default:"false"`
+	} `yaml:"health"`
+
+	RateLimit RateLimit `yaml:"rateLimit"`
+
+	Idempotency struct {
+		KeyTTL time.Duration `env:"IDEMPOTENCY_KEY_TTL" yaml:"keyTTL" default:"24h"`
+	} `yaml:"idempotency"`
+
+	Retention struct {
+		SessionsMonths int           `env:"RETENTION_SESSIONS_MONTHS" yaml:"sessionsMonths" default:"0"`
+		Interval       time.Duration `env:"RETENTION_INTERVAL" yaml:"interval" default:"24h"`
+	} `yaml:"retention"`
+
+	PeopleService struct {
+		URL            string        `env:"PEOPLE_SERVICE_URL" yaml:"url" default:"http://localhost:8081"`
+		RequestTimeout time.Duration `env:"PEOPLE_SERVICE_REQUEST_TIMEOUT" yaml:"requestTimeout" default:"10s"`
+		SyncInterval   time.Duration `env:"PEOPLE_SERVICE_SYNC_INTERVAL" yaml:"syncInterval" default:"24h"`
+		SyncBatchSize  int           `env:"PEOPLE_SERVICE_SYNC_BATCH_SIZE" yaml:"syncBatchSize" default:"100"`
+		SyncRate       int           `env:"PEOPLE_SERVICE_SYNC_RATE" yaml:"syncRate" default:"5"`
+	} `yaml:"peopleService"`
+}
+
+// DB is the database connection and pool configuration.
+type DB struct {
+	// DSN is the connection string without the protocol: <user>:<password>@<host>:<port>/<db>?<options>.
+	DSN             string        `env:"DB_DSN" yaml:"dsn" default:"postgres:postgres@localhost:5432/postgres" secret:"dsn"`
+	Automigrate     bool          `env:"DB_AUTOMIGRATE" yaml:"automigrate" default:"true"`
+	ConnectTimeout  time.Duration `env:"DB_CONNECT_TIMEOUT" yaml:"connectTimeout" default:"3s"`
+	MaxOpenConns    int           `env:"DB_MAX_OPEN_CONNS" yaml:"maxOpenConns" default:"25"`
+	MaxIdleConns    int           `env:"DB_MAX_IDLE_CONNS" yaml:"maxIdleConns" default:"25"`
+	ConnMaxIdleTime time.Duration `env:"DB_CONN_MAX_IDLE_TIME" yaml:"connMaxIdleTime" default:"5m"`
+	ConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME" yaml:"connMaxLifetime" default:"2h"`
+}
+
+// RateLimit is the per client rate limit configuration.
+type RateLimit struct {
+	Enabled    bool    `env:"RATE_LIMIT_ENABLED" yaml:"enabled" default:"true"`
+	ReadRate   float64 `env:"RATE_LIMIT_READ_RATE" yaml:"readRate" default:"20"`
+	ReadBurst  int     `env:"RATE_LIMIT_READ_BURST" yaml:"readBurst" default:"40"`
+	WriteRate  float64 `env:"RATE_LIMIT_WRITE_RATE" yaml:"writeRate" default:"5"`
+	WriteBurst int     `env:"RATE_LIMIT_WRITE_BURST" yaml:"writeBurst" default:"10"`
+}
+
+// Read returns the limit of GET, HEAD and OPTIONS requests.
+func (rl RateLimit) Read() ratelimit.Limit {
+	return ratelimit.Limit{Rate: rl.ReadRate, Burst: rl.ReadBurst}
+}
+
+// Write returns the limit of the other requests.
+func (rl RateLimit) Write() ratelimit.Limit {
+	return ratelimit.Limit{Rate: rl.WriteRate, Burst: rl.WriteBurst}
+}
+
+// RedactedDSN hides the password of the connection string.
+func (db DB) RedactedDSN() string {
+	return redactDSN(db.DSN)
+}
+
+// validate reports every invalid value, not only the first one.
+func (c Config) validate() []error {
+	var errs []error
+	check := func(ok bool, name, message string) {
+		if !ok {
+			errs = append(errs, &fieldError{name: name, message: message})
+		}
+	}
+
+	check(c.HTTP.Port > 0 && c.HTTP.Port <= 65535, "HTTP_PORT", "must be between 1 and 65535")
+	check(c.HTTP.ReadTimeout > 0, "HTTP_READ_TIMEOUT", "must be positive")
+	check(c.HTTP.WriteTimeout > 0, "HTTP_WRITE_TIMEOUT", "must be positive")
+	check(c.HTTP.IdleTimeout > 0, "HTTP_IDLE_TIMEOUT", "must be positive")
+	check(c.HTTP.ShutdownPeriod > 0, "HTTP_SHUTDOWN_PERIOD", "must be positive")
+
+	if c.Metrics.Enabled {
+		check(c.Metrics.Port > 0 && c.Metrics.Port <= 65535, "METRICS_PORT", "must be between 1 and 65535")
+		check(c.Metrics.Port != c.HTTP.Port, "METRICS_PORT", "must differ from HTTP_PORT")
+	}
+
+	check(c.DB.DSN != "", "DB_DSN", "must be set")
+	check(c.DB.ConnectTimeout > 0, "DB_CONNECT_TIMEOUT", "must be positive")
+	check(c.DB.MaxOpenConns > 0, "DB_MAX_OPEN_CONNS", "must be positive")
+	check(c.DB.MaxIdleConns >= 0, "DB_MAX_IDLE_CONNS", "must not be negative")
+	check(c.DB.MaxIdleConns <= c.DB.MaxOpenConns, "DB_MAX_IDLE_CONNS", "must not be greater than DB_MAX_OPEN_CONNS")
+	check(c.DB.ConnMaxIdleTime >= 0, "DB_CONN_MAX_IDLE_TIME", "must not be negative")
+	check(c.DB.ConnMaxLifetime >= 0, "DB_CONN_MAX_LIFETIME", "must not be negative")
+
+	check(c.Auth.AccessTokenTTL > 0, "AUTH_ACCESS_TOKEN_TTL", "must be positive")
+	check(c.Auth.RefreshTokenTTL > 0, "AUTH_REFRESH_TOKEN_TTL", "must be positive")
+	check(c.Auth.LoginCodeTTL > 0, "AUTH_LOGIN_CODE_TTL", "must be positive")
+
+	check(
+		c.Identity.Provider == identity.ProviderPeopleService || c.Identity.Provider == identity.ProviderManual,
+		"IDENTITY_PROVIDER", fmt.Sprintf("must be one of %s, %s", identity.ProviderPeopleService, identity.ProviderManual),
+	)
+
+	if c.Passport.EncryptionKeys == "" {
+		check(false, "PASSPORT_ENCRYPTION_KEYS", "must be set, generate keys with -genPassportKey")
+	} else if keys, err := pii.ParseKeys(c.Passport.EncryptionKeys); err != nil {
+		check(false, "PASSPORT_ENCRYPTION_KEYS", err.Error())
+	} else if c.Passport.EncryptionKeyID != 0 {
+		_, ok := keys[c.Passport.EncryptionKeyID]
+		check(ok, "PASSPORT_ENCRYPTION_KEY_ID", "must be one of PASSPORT_ENCRYPTION_KEYS")
+	}
+	if c.Passport.IndexKey == "" {
+		check(false, "PASSPORT_INDEX_KEY", "must be set, generate a key with -genPassportKey")
+	} else if _, err := base64.StdEncoding.DecodeString(c.Passport.IndexKey); err != nil {
+		check(false, "PASSPORT_INDEX_KEY", "must be base64 encoded")
+	}
+
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "TRACING_SAMPLE_RATIO", "must be between 0 and 1")
+
+	if c.RateLimit.Enabled {
+		if err := c.RateLimit.Read().Validate(); err != nil {
+			check(false, "RATE_LIMIT_READ_*", err.Error())
+		}
+		if err := c.RateLimit.Write().Validate(); err != nil {
+			check(false, "RATE_LIMIT_WRITE_*", err.Error())
+		}
+	}
+
+	check(c.Idempotency.KeyTTL >= 0, "IDEMPOTENCY_KEY_TTL", "must not be negative")
+
+	check(c.Retention.SessionsMonths >= 0, "RETENTION_SESSIONS_MONTHS", "must not be negative")
+	check(c.Retention.Interval > 0, "RETENTION_INTERVAL", "must be positive")
+
+	if c.Identity.Provider == identity.ProviderPeopleService {
+		u, err := url.Parse(c.PeopleService.URL)
+		check(err == nil && u.Scheme != "" && u.Host != "", "PEOPLE_SERVICE_URL", "must be an absolute URL")
+	}
+	check(c.PeopleService.RequestTimeout > 0, "PEOPLE_SERVICE_REQUEST_TIMEOUT", "must be positive")
+	check(c.PeopleService.SyncInterval >= 0, "PEOPLE_SERVICE_SYNC_INTERVAL", "must not be negative")
+	check(c.PeopleService.SyncBatchSize > 0, "PEOPLE_SERVICE_SYNC_BATCH_SIZE", "must be positive")
+	check(c.PeopleService.SyncRate > 0, "PEOPLE_SERVICE_SYNC_RATE", "must be positive")
+
+	return errs
+}
+
+type fieldError struct {
+	name    string
+	message string
+}
+
+func (e *fieldError) Error() string {
+	return e.name + ": " + e.message
+}
+
+// ErrInvalid is wrapped by the error of Load, the error lists all invalid values.
+var ErrInvalid = errors.New("invalid configuration")
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration from defaults, YAML files (.yaml, .yml), .env files (any other extension)
+// and the environment, each source overrides the previous ones. All malformed and invalid values
+// are reported at once.
+func Load(files ...string) (Config, error) {
+	var cfg Config
+
+	fields := collectFields(reflect.ValueOf(&cfg).Elem(), "")
+
+	values := make(map[string]string, len(fields))
+	for _, f := range fields {
+		if f.def != "" {
+			values[f.env] = f.def
+		}
+	}
+
+	var errs []error
+
+	for _, file := range files {
+		ext := strings.ToLower(filepath.Ext(file))
+		if ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+
+		if err := readYAML(file, fields, values); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, file := range files {
+		ext := strings.ToLower(filepath.Ext(file))
+		if ext == ".yaml" || ext == ".yml" {
+			continue
+		}
+
+		envValues, err := godotenv.Read(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("read %s: %w", file, err))
+			continue
+		}
+
+		for _, f := range fields {
+			if value, ok := envValues[f.env]; ok {
+				values[f.env] = value
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if value, ok := os.LookupEnv(f.env); ok {
+			values[f.env] = value
+		}
+	}
+
+	malformed := make(map[string]bool)
+	for _, f := range fields {
+		value, ok := values[f.env]
+		if !ok {
+			continue
+		}
+
+		if err := setValue(f.value, value); err != nil {
+			malformed[f.env] = true
+			errs = append(errs, fmt.Errorf("%s: invalid value %q: %w", f.env, value, err))
+		}
+	}
+
+	for _, err := range cfg.validate() {
+		var fieldErr *fieldError
+		if errors.As(err, &fieldErr) && malformed[fieldErr.name] {
+			continue
+		}
+
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return Config{}, fmt.Errorf("%w:\n%w", ErrInvalid, errors.Join(errs...))
+	}
+
+	return cfg, nil
+}
+
+// field is a configuration value settable from the environment.
+type field struct {
+	env    string
+	path   string
+	def    string
+	secret string
+	value  reflect.Value
+}
+
+func collectFields(v reflect.Value, prefix string) []field {
+	var fields []field
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		path := sf.Tag.Get("yaml")
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		if env, ok := sf.Tag.Lookup("env"); ok {
+			fields = append(fields, field{
+				env:    env,
+				path:   path,
+				def:    sf.Tag.Get("default"),
+				secret: sf.Tag.Get("secret"),
+				value:  v.Field(i),
+			})
+			continue
+		}
+
+		if sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, collectFields(v.Field(i), path)...)
+		}
+	}
+
+	return fields
+}
+
+func readYAML(file string, fields []field, values map[string]string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", file, err)
+	}
+
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parse %s: %w", file, err)
+	}
+
+	byPath := make(map[string]string, len(fields))
+	for _, f := range fields {
+		byPath[f.path] = f.env
+	}
+
+	var errs []error
+	flattenYAML(doc, "", func(path string, value any) {
+		env, ok := byPath[path]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown key %s", file, path))
+			return
+		}
+
+		switch value.(type) {
+		case map[string]any, []any:
+			errs = append(errs, fmt.Errorf("%s: %s must be a scalar", file, path))
+		case nil:
+			delete(values, env)
+		default:
+			values[env] = fmt.Sprint(value)
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+func flattenYAML(doc map[string]any, prefix string, visit func(path string, value any)) {
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		if nested, ok := doc[key].(map[string]any); ok {
+			flattenYAML(nested, path, visit)
+			continue
+		}
+
+		visit(path, doc[key])
+	}
+}
+
+var _durationType = reflect.TypeOf(time.Duration(0))
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == _durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return errors.New("must be a duration, e.g. 5s, 1m, 2h")
+		}
+
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+
+		v.SetBool(b)
+
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+
+		v.SetInt(int64(n))
+
+	case reflect.Uint:
+		n, err := strconv.ParseUint(s, 10, 0)
+		if err != nil {
+			return errors.New("must be a non-negative integer")
+		}
+
+		v.SetUint(n)
+
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+
+		v.SetFloat(f)
+
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+const _redacted = "REDACTED"
+
+// Print writes the effective configuration as KEY=value lines, secrets are redacted.
+func (c Config) Print(w io.Writer) error {
+	for _, f := range collectFields(reflect.ValueOf(&c).Elem(), "") {
+		value := fmt.Sprint(f.value.Interface())
+
+		switch f.secret {
+		case "":
+		case "dsn":
+			value = redactDSN(value)
+		default:
+			if value != "" {
+				value = _redacted
+			}
+		}
+
+		if _, err := fmt.Fprintf(w, "%s=%s\n", f.env, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// redactDSN hides the password of a connection string with or without the protocol.
+func redactDSN(dsn string) string {
+	const scheme = "postgres://"
+
+	withScheme := strings.Contains(dsn, "://")
+	if !withScheme {
+		dsn = scheme + dsn
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return _redacted
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), _redacted)
+	}
+
+	redacted := u.String()
+	if !withScheme {
+		redacted = strings.TrimPrefix(redacted, scheme)
+	}
+
+	return redacted
+}
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+type APIKeyDAO struct {
+	Logger *slog.Logger
+	*DB
+}
+
+func NewAPIKeyDAO(logger *slog.Logger, db *DB) *APIKeyDAO {
+	return &APIKeyDAO{
+		Logger: logger.With("dao", "apiKey"),
+		DB:     db,
+	}
+}
+
+func (dao *APIKeyDAO) Find(ctx context.Context, opts FindOptions) ([]model.APIKey, error) {
+	logger := dao.Logger.With("query", "find")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return []model.APIKey{}, err
+	}
+
+	query, args, err := dao.Builder.
+		Select("*").
+		From("api_keys").
+		Where(squirrel.Eq{"organization_id": tenant}).
+		OrderBy("created_at ASC").
+		Limit(opts.Limit).
+		Offset(opts.Offset).
+		ToSql()
+	if err != nil {
+		return []model.APIKey{}, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	keys := make([]model.APIKey, 0, opts.Limit)
+	if err := dao.SelectContext(ctx, &keys, query, args...); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return []model.APIKey{}, err
+	}
+
+	logger.Debug("success query execute", "countKeys", len(keys))
+
+	return keys, nil
+}
+
+func (dao *APIKeyDAO) Get(ctx context.Context, id model.ID) (model.APIKey, error) {
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return model.APIKey{}, err
+	}
+
+	return dao.getBy(ctx, "get", squirrel.Eq{"id": id, "organization_id": tenant})
+}
+
+// GetActiveByHash is not tenant scoped, the organization of the key is resolved by it.
+func (dao *APIKeyDAO) GetActiveByHash(ctx context.Context, keyHash string) (model.APIKey, error) {
+	return dao.getBy(ctx, "getActiveByHash", squirrel.Eq{"key_hash": keyHash, "revoked_at": nil})
+}
+
+func (dao *APIKeyDAO) getBy(ctx context.Context, queryName string, where squirrel.Eq) (model.APIKey, error) {
+	logger := dao.Logger.With("query", queryName)
+
+	query, args, err := dao.Builder.
+		Select("*").
+		From("api_keys").
+		Where(where).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return model.APIKey{}, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var key model.APIKey
+	row := dao.QueryRowxContext(ctx, query, args...)
+	if err := row.StructScan(&key); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		if IsNoRows(err) {
+			return model.APIKey{}, model.NewError("API key", model.ErrNotFound)
+		}
+
+		return model.APIKey{}, err
+	}
+
+	logger.Debug("success query execute", "apiKeyId", key.ID)
+
+	return key, nil
+}
+
+type InsertAPIKeyDTO struct {
+	Name    string
+	Prefix  string
+	KeyHash string
+	User    *model.ID
+
+	CanViewPassports bool
+}
+
+func (dao *APIKeyDAO) Insert(ctx context.Context, dto InsertAPIKeyDTO) (model.ID, error) {
+	logger := dao.Logger.With("query", "insert")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query, args, err := dao.Builder.
+		Insert("api_keys").
+		Columns("organization_id", "name", "prefix", "key_hash", "user_id", "can_view_passports").
+		Values(tenant, dto.Name, dto.Prefix, dto.KeyHash, dto.User, dto.CanViewPassports).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var id model.ID
+	row := dao.QueryRowxContext(ctx, query, args...)
+	if err := row.Scan(&id); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		if IsUniqueViolation(err) {
+			return 0, model.NewError("API key", model.ErrExists)
+		}
+
+		return 0, err
+	}
+
+	logger.Debug("success query execute", "insertId", id)
+
+	return id, nil
+}
+
+func (dao *APIKeyDAO) Revoke(ctx context.Context, id model.ID) error {
+	logger := dao.Logger.With("query", "revoke")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	query, args, err := dao.Builder.
+		Update("api_keys").
+		SetMap(map[string]any{
+			"updated_at": now,
+			"revoked_at": now,
+		}).
+		Where(squirrel.Eq{"id": id, "organization_id": tenant, "revoked_at": nil}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	if _, err = dao.ExecContext(ctx, query, args...); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return err
+	}
+
+	logger.Debug("success query execute", "revokeId", id)
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/protomem/time-tracker/internal/ctxstore"
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+const (
+	AuditEntityUser    = "user"
+	AuditEntitySession = "session"
+	// AuditEntitySessionArchive is a batch of sessions moved into summaries by the retention job.
+	AuditEntitySessionArchive = "sessionArchive"
+)
+
+const _actorKey = ctxstore.Key("auditActor")
+
+// Actor is the caller recorded in the audit log, zero value is the system (e.g. background jobs).
+type Actor struct {
+	APIKey  *model.ID
+	User    *model.ID
+	TraceID string
+}
+
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return ctxstore.With(ctx, _actorKey, actor)
+}
+
+func ActorFromContext(ctx context.Context) Actor {
+	actor, _ := ctxstore.From[Actor](ctx, _actorKey)
+	return actor
+}
+
+type AuditDAO struct {
+	Logger *slog.Logger
+	*DB
+}
+
+func NewAuditDAO(logger *slog.Logger, db *DB) *AuditDAO {
+	return &AuditDAO{
+		Logger: logger.With("dao", "audit"),
+		DB:     db,
+	}
+}
+
+type FindAuditFilter struct {
+	Entity      *string
+	EntityID    *model.ID
+	Action      *model.AuditAction
+	ActorUser   *model.ID
+	ActorAPIKey *model.ID
+	After       *time.Time
+	Before      *time.Time
+}
+
+func (dao *AuditDAO) Find(ctx context.Context, filter FindAuditFilter, opts FindOptions) ([]model.AuditEntry, error) {
+	logger := dao.Logger.With("query", "find")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return []model.AuditEntry{}, err
+	}
+
+	equals := squirrel.Eq{"organization_id": tenant}
+	if filter.Entity != nil {
+		equals["entity"] = *filter.Entity
+	}
+	if filter.EntityID != nil {
+		equals["entity_id"] = *filter.EntityID
+	}
+	if filter.Action != nil {
+		equals["action"] = *filter.Action
+	}
+	if filter.ActorUser != nil {
+		equals["actor_user_id"] = *filter.ActorUser
+	}
+	if filter.ActorAPIKey != nil {
+		equals["actor_api_key_id"] = *filter.ActorAPIKey
+	}
+
+	stmt := dao.Builder.
+		Select("*").
+		From("audit_log").
+		Where(equals).
+		OrderBy("created_at DESC", "id DESC").
+		Limit(opts.Limit).
+		Offset(opts.Offset)
+
+	if filter.After != nil {
+		stmt = stmt.Where(squirrel.GtOrEq{"created_at": *filter.After})
+	}
+	if filter.Before != nil {
+		stmt = stmt.Where(squirrel.Lt{"created_at": *filter.Before})
+	}
+
+	query, args, err := stmt.ToSql()
+	if err != nil {
+		return []model.AuditEntry{}, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	entries := make([]model.AuditEntry, 0, opts.Limit)
+	if err := dao.SelectContext(ctx, &entries, query, args...); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return []model.AuditEntry{}, err
+	}
+
+	logger.Debug("success query execute", "countEntries", len(entries))
+
+	return entries, nil
+}
+
+// insertAuditEntry records the change within the transaction of the change itself.
+// Before is nil for created and after is nil for deleted entities.
+func (db *DB) insertAuditEntry(
+	ctx context.Context, tx *sqlx.Tx, logger *slog.Logger,
+	entity string, entityID model.ID, action model.AuditAction,
+	before, after any,
+) error {
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	beforeJSON, afterJSON, err := auditDiff(before, after)
+	if err != nil {
+		return err
+	}
+
+	if action == model.AuditUpdate && string(afterJSON) == "{}" {
+		logger.Debug("nothing changed, skip audit entry", "entity", entity, "entityId", entityID)
+		return nil
+	}
+
+	actor := ActorFromContext(ctx)
+
+	var traceID *string
+	if actor.TraceID != "" {
+		traceID = &actor.TraceID
+	}
+
+	query, args, err := db.Builder.
+		Insert("audit_log").
+		Columns("organization_id", "actor_api_key_id", "actor_user_id", "action", "entity", "entity_id", "before", "after", "trace_id").
+		Values(tenant, actor.APIKey, actor.User, action, entity, entityID, beforeJSON, afterJSON, traceID).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("build query", "query", "insertAuditEntry", "sql", query, "args", args)
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		logger.Warn("failed query execute", "query", "insertAuditEntry", "error", err)
+
+		return err
+	}
+
+	return nil
+}
+
+// auditDiff encodes entity states, for updates only changed fields are kept.
+func auditDiff(before, after any) (model.RawJSON, model.RawJSON, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if beforeFields != nil && afterFields != nil {
+		for key, value := range beforeFields {
+			if bytes.Equal(value, afterFields[key]) || key == "updatedAt" {
+				delete(beforeFields, key)
+				delete(afterFields, key)
+			}
+		}
+	}
+
+	beforeJSON, err := marshalAuditFields(beforeFields)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	afterJSON, err := marshalAuditFields(afterFields)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return beforeJSON, afterJSON, nil
+}
+
+func auditFields(state any) (map[string]json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+func marshalAuditFields(fields map[string]json.RawMessage) (model.RawJSON, error) {
+	if fields == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(fields)
+}
@@ -0,0 +1,224 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+type AuthSessionDAO struct {
+	Logger *slog.Logger
+	*DB
+}
+
+func NewAuthSessionDAO(logger *slog.Logger, db *DB) *AuthSessionDAO {
+	return &AuthSessionDAO{
+		Logger: logger.With("dao", "authSession"),
+		DB:     db,
+	}
+}
+
+func (dao *AuthSessionDAO) Get(ctx context.Context, id model.ID) (model.AuthSession, error) {
+	logger := dao.Logger.With("query", "get")
+
+	query, args, err := dao.Builder.
+		Select("*").
+		From("auth_sessions").
+		Where(squirrel.Eq{"id": id}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return model.AuthSession{}, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var session model.AuthSession
+	row := dao.QueryRowxContext(ctx, query, args...)
+	if err := row.StructScan(&session); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		if IsNoRows(err) {
+			return model.AuthSession{}, model.NewError("auth session", model.ErrNotFound)
+		}
+
+		return model.AuthSession{}, err
+	}
+
+	logger.Debug("success query execute", "authSessionId", session.ID)
+
+	return session, nil
+}
+
+type InsertAuthSessionDTO struct {
+	Organization model.ID
+	User         model.ID
+	ExpiresAt    time.Time
+}
+
+func (dao *AuthSessionDAO) Insert(ctx context.Context, dto InsertAuthSessionDTO) (model.ID, error) {
+	logger := dao.Logger.With("query", "insert")
+
+	query, args, err := dao.Builder.
+		Insert("auth_sessions").
+		Columns("organization_id", "user_id", "expires_at").
+		Values(dto.Organization, dto.User, dto.ExpiresAt).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var id model.ID
+	row := dao.QueryRowxContext(ctx, query, args...)
+	if err := row.Scan(&id); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return 0, err
+	}
+
+	logger.Debug("success query execute", "insertId", id)
+
+	return id, nil
+}
+
+func (dao *AuthSessionDAO) Revoke(ctx context.Context, id model.ID) error {
+	return dao.revokeBy(ctx, "revoke", squirrel.Eq{"id": id})
+}
+
+func (dao *AuthSessionDAO) RevokeByUser(ctx context.Context, user model.ID) error {
+	return dao.revokeBy(ctx, "revokeByUser", squirrel.Eq{"user_id": user})
+}
+
+func (dao *AuthSessionDAO) revokeBy(ctx context.Context, queryName string, where squirrel.Eq) error {
+	logger := dao.Logger.With("query", queryName)
+
+	now := time.Now()
+	query, args, err := dao.Builder.
+		Update("auth_sessions").
+		SetMap(map[string]any{
+			"updated_at": now,
+			"revoked_at": now,
+		}).
+		Where(where).
+		Where(squirrel.Eq{"revoked_at": nil}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	res, err := dao.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return err
+	}
+
+	count, _ := res.RowsAffected()
+	logger.Debug("success query execute", "countRevoked", count)
+
+	return nil
+}
+
+func (dao *AuthSessionDAO) GetRefreshToken(ctx context.Context, tokenHash string) (model.RefreshToken, error) {
+	logger := dao.Logger.With("query", "getRefreshToken")
+
+	query, args, err := dao.Builder.
+		Select("*").
+		From("refresh_tokens").
+		Where(squirrel.Eq{"token_hash": tokenHash}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return model.RefreshToken{}, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var token model.RefreshToken
+	row := dao.QueryRowxContext(ctx, query, args...)
+	if err := row.StructScan(&token); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		if IsNoRows(err) {
+			return model.RefreshToken{}, model.NewError("refresh token", model.ErrNotFound)
+		}
+
+		return model.RefreshToken{}, err
+	}
+
+	logger.Debug("success query execute", "refreshTokenId", token.ID)
+
+	return token, nil
+}
+
+func (dao *AuthSessionDAO) InsertRefreshToken(ctx context.Context, session model.ID, tokenHash string) (model.ID, error) {
+	logger := dao.Logger.With("query", "insertRefreshToken")
+
+	query, args, err := dao.Builder.
+		Insert("refresh_tokens").
+		Columns("session_id", "token_hash").
+		Values(session, tokenHash).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var id model.ID
+	row := dao.QueryRowxContext(ctx, query, args...)
+	if err := row.Scan(&id); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		if IsUniqueViolation(err) {
+			return 0, model.NewError("refresh token", model.ErrExists)
+		}
+
+		return 0, err
+	}
+
+	logger.Debug("success query execute", "insertId", id)
+
+	return id, nil
+}
+
+// UseRefreshToken marks the token as used, it reports false if the token was already used.
+func (dao *AuthSessionDAO) UseRefreshToken(ctx context.Context, id model.ID) (bool, error) {
+	logger := dao.Logger.With("query", "useRefreshToken")
+
+	query, args, err := dao.Builder.
+		Update("refresh_tokens").
+		Set("used_at", time.Now()).
+		Where(squirrel.Eq{"id": id, "used_at": nil}).
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	res, err := dao.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return false, err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	logger.Debug("success query execute", "refreshTokenId", id, "used", count == 1)
+
+	return count == 1, nil
+}
@@ -4,55 +4,68 @@ import (
 	"context"
 	"errors"
 	"log/slog"
-	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/protomem/time-tracker/assets"
+	"github.com/protomem/time-tracker/internal/config"
+	"github.com/protomem/time-tracker/internal/pii"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/trace"
 
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
 const (
-	_defaultTimeout = 3 * time.Second
-	_driverName     = "pgx"
+	_driverName = "pgx"
+	_tracerName = "github.com/protomem/time-tracker/internal/database"
 )
 
 type DB struct {
 	*sqlx.DB
 	Builder squirrel.StatementBuilderType
 	Logger  *slog.Logger
+
+	// PII encrypts personal data, it is set after connect.
+	PII *pii.Protector
 }
 
-func New(logger *slog.Logger, dsn string, automigrate bool) (*DB, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), _defaultTimeout)
+func New(logger *slog.Logger, cfg config.DB, tracerProvider trace.TracerProvider) (*DB, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
 	defer cancel()
 
 	logger = logger.With("module", "database")
 
-	db, err := sqlx.ConnectContext(ctx, _driverName, "postgres://"+dsn)
+	connConfig, err := pgx.ParseConfig("postgres://" + cfg.DSN)
 	if err != nil {
 		return nil, err
 	}
+	connConfig.Tracer = &queryTracer{tracer: tracerProvider.Tracer(_tracerName)}
+
+	db := sqlx.NewDb(stdlib.OpenDB(*connConfig), _driverName)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
 
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxIdleTime(5 * time.Minute)
-	db.SetConnMaxLifetime(2 * time.Hour)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
-	logger.Info("connect to database", "dsn", dsn)
+	logger.Info("connect to database", "dsn", cfg.RedactedDSN())
 
-	if automigrate {
+	if cfg.Automigrate {
 		iofsDriver, err := iofs.New(assets.EmbeddedFiles, "migrations")
 		if err != nil {
 			return nil, err
 		}
 
-		migrator, err := migrate.NewWithSourceInstance("iofs", iofsDriver, "postgres://"+dsn)
+		migrator, err := migrate.NewWithSourceInstance("iofs", iofsDriver, "postgres://"+cfg.DSN)
 		if err != nil {
 			return nil, err
 		}
@@ -79,3 +92,18 @@ func (db *DB) Close() error {
 	db.Logger.Info("disconnect from database")
 	return db.DB.Close()
 }
+
+// withTx runs fn in a transaction, the transaction is rolled back if fn fails.
+func (db *DB) withTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,263 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+// _idempotencyKeyColumns are columns of model.IdempotencyKey, users of the response are only written.
+var _idempotencyKeyColumns = []string{
+	"created_at", "organization_id", "scope", "key", "request_hash",
+	"status_code", "response_headers", "response_body", "response_key_id", "expires_at",
+}
+
+type IdempotencyKeyDAO struct {
+	Logger *slog.Logger
+	*DB
+}
+
+func NewIdempotencyKeyDAO(logger *slog.Logger, db *DB) *IdempotencyKeyDAO {
+	return &IdempotencyKeyDAO{
+		Logger: logger.With("dao", "idempotencyKey"),
+		DB:     db,
+	}
+}
+
+type AcquireIdempotencyKeyDTO struct {
+	Scope       string
+	Key         string
+	RequestHash string
+	ExpiresAt   time.Time
+}
+
+// Acquire inserts the key or takes over the expired one and reports true,
+// otherwise it returns the existing key and reports false.
+func (dao *IdempotencyKeyDAO) Acquire(ctx context.Context, dto AcquireIdempotencyKeyDTO) (model.IdempotencyKey, bool, error) {
+	logger := dao.Logger.With("query", "acquire")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return model.IdempotencyKey{}, false, err
+	}
+
+	query, args, err := dao.Builder.
+		Insert("idempotency_keys").
+		Columns("organization_id", "scope", "key", "request_hash", "expires_at").
+		Values(tenant, dto.Scope, dto.Key, dto.RequestHash, dto.ExpiresAt).
+		Suffix(`ON CONFLICT (organization_id, scope, key) DO UPDATE SET
+			created_at = now(),
+			request_hash = EXCLUDED.request_hash,
+			status_code = NULL,
+			response_headers = NULL,
+			response_body = NULL,
+			response_key_id = NULL,
+			user_ids = '{}',
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= now()
+		RETURNING ` + strings.Join(_idempotencyKeyColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return model.IdempotencyKey{}, false, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var key model.IdempotencyKey
+	row := dao.QueryRowxContext(ctx, query, args...)
+	if err := row.StructScan(&key); err != nil {
+		if !IsNoRows(err) {
+			logger.Warn("failed query execute", "error", err)
+			return model.IdempotencyKey{}, false, err
+		}
+
+		logger.Debug("success query execute", "acquired", false)
+
+		key, err = dao.get(ctx, tenant, dto.Scope, dto.Key)
+		return key, false, err
+	}
+
+	logger.Debug("success query execute", "acquired", true)
+
+	return key, true, nil
+}
+
+func (dao *IdempotencyKeyDAO) get(ctx context.Context, tenant model.ID, scope, key string) (model.IdempotencyKey, error) {
+	logger := dao.Logger.With("query", "get")
+
+	query, args, err := dao.Builder.
+		Select(_idempotencyKeyColumns...).
+		From("idempotency_keys").
+		Where(squirrel.Eq{"organization_id": tenant, "scope": scope, "key": key}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return model.IdempotencyKey{}, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var idempotencyKey model.IdempotencyKey
+	row := dao.QueryRowxContext(ctx, query, args...)
+	if err := row.StructScan(&idempotencyKey); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		if IsNoRows(err) {
+			return model.IdempotencyKey{}, model.NewError("idempotency key", model.ErrNotFound)
+		}
+
+		return model.IdempotencyKey{}, err
+	}
+
+	logger.Debug("success query execute")
+
+	return dao.decodeResponse(idempotencyKey)
+}
+
+// decodeResponse decrypts the stored response body.
+func (dao *IdempotencyKeyDAO) decodeResponse(key model.IdempotencyKey) (model.IdempotencyKey, error) {
+	if key.ResponseKeyID == nil || key.ResponseBody == nil {
+		return key, nil
+	}
+
+	if dao.PII == nil {
+		return model.IdempotencyKey{}, ErrPIIRequired
+	}
+
+	body, err := dao.PII.Decrypt(*key.ResponseKeyID, string(key.ResponseBody))
+	if err != nil {
+		return model.IdempotencyKey{}, err
+	}
+	key.ResponseBody = []byte(body)
+
+	return key, nil
+}
+
+type CompleteIdempotencyKeyDTO struct {
+	StatusCode      int
+	ResponseHeaders model.RawJSON
+	ResponseBody    []byte
+
+	// Users are referred to by the response, it is deleted when any of them is erased or deleted.
+	Users []model.ID
+}
+
+// Complete stores the response of the request which acquired the key, the body is encrypted.
+func (dao *IdempotencyKeyDAO) Complete(ctx context.Context, scope, key string, dto CompleteIdempotencyKeyDTO) error {
+	logger := dao.Logger.With("query", "complete")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if dao.PII == nil {
+		return ErrPIIRequired
+	}
+
+	body, err := dao.PII.Encrypt(string(dto.ResponseBody))
+	if err != nil {
+		return err
+	}
+
+	users := make([]int64, 0, len(dto.Users))
+	for _, user := range dto.Users {
+		users = append(users, int64(user))
+	}
+
+	query, args, err := dao.Builder.
+		Update("idempotency_keys").
+		Set("status_code", dto.StatusCode).
+		Set("response_headers", dto.ResponseHeaders).
+		Set("response_body", []byte(body)).
+		Set("response_key_id", dao.PII.PrimaryKey()).
+		Set("user_ids", squirrel.Expr("?::integer[]", users)).
+		Where(squirrel.Eq{"organization_id": tenant, "scope": scope, "key": key, "status_code": nil}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	res, err := dao.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Warn("failed query execute", "error", err)
+		return err
+	}
+
+	if count, err := res.RowsAffected(); err == nil && count == 0 {
+		return model.NewError("idempotency key", model.ErrNotFound)
+	}
+
+	logger.Debug("success query execute")
+
+	return nil
+}
+
+// Release deletes the key of the request which has not completed, so the request can be retried.
+func (dao *IdempotencyKeyDAO) Release(ctx context.Context, scope, key string) error {
+	logger := dao.Logger.With("query", "release")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := dao.Builder.
+		Delete("idempotency_keys").
+		Where(squirrel.Eq{"organization_id": tenant, "scope": scope, "key": key, "status_code": nil}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	if _, err := dao.ExecContext(ctx, query, args...); err != nil {
+		logger.Warn("failed query execute", "error", err)
+		return err
+	}
+
+	logger.Debug("success query execute")
+
+	return nil
+}
+
+// deleteIdempotentResponses deletes stored responses referring to the user, see CompleteIdempotencyKeyDTO.Users.
+func deleteIdempotentResponses(builder squirrel.StatementBuilderType, tenant, userID model.ID) squirrel.Sqlizer {
+	return builder.
+		Delete("idempotency_keys").
+		Where(squirrel.Eq{"organization_id": tenant}).
+		Where(squirrel.Expr("? = ANY(user_ids)", userID))
+}
+
+// DeleteExpired deletes expired keys of all organizations.
+func (dao *IdempotencyKeyDAO) DeleteExpired(ctx context.Context) (int64, error) {
+	logger := dao.Logger.With("query", "deleteExpired")
+
+	query, args, err := dao.Builder.
+		Delete("idempotency_keys").
+		Where(squirrel.LtOrEq{"expires_at": time.Now()}).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	res, err := dao.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Warn("failed query execute", "error", err)
+		return 0, err
+	}
+
+	count, _ := res.RowsAffected()
+	logger.Debug("success query execute", "countDeleted", count)
+
+	return count, nil
+}
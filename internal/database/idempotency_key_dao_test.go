@@ -0,0 +1,86 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+func acquireTestIdempotencyKey(t *testing.T, ctx context.Context, dao *IdempotencyKeyDAO, scope, key string) {
+	t.Helper()
+
+	if _, acquired, err := dao.Acquire(ctx, AcquireIdempotencyKeyDTO{
+		Scope:       scope,
+		Key:         key,
+		RequestHash: "hash",
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}); err != nil || !acquired {
+		t.Fatalf("acquire idempotency key: acquired %v, error %v", acquired, err)
+	}
+}
+
+func TestIdempotencyKeyResponseEncrypted(t *testing.T) {
+	db := newTestDB(t)
+	ctx, _ := newTestTenants(t, db)
+	dao := NewIdempotencyKeyDAO(db.Logger, db)
+
+	scope := fmt.Sprintf("test:%d", time.Now().UnixNano())
+	body := []byte(`{"passportNumber":"123456"}`)
+
+	acquireTestIdempotencyKey(t, ctx, dao, scope, "key")
+	if err := dao.Complete(ctx, scope, "key", CompleteIdempotencyKeyDTO{StatusCode: 201, ResponseBody: body}); err != nil {
+		t.Fatalf("complete idempotency key: %v", err)
+	}
+
+	var stored []byte
+	if err := db.QueryRowxContext(ctx, "SELECT response_body FROM idempotency_keys WHERE scope = $1", scope).Scan(&stored); err != nil {
+		t.Fatalf("select response body: %v", err)
+	}
+	if bytes.Contains(stored, []byte("123456")) {
+		t.Fatalf("response body is stored in plain text")
+	}
+
+	key, acquired, err := dao.Acquire(ctx, AcquireIdempotencyKeyDTO{Scope: scope, Key: "key", RequestHash: "hash", ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil || acquired {
+		t.Fatalf("acquire completed idempotency key: acquired %v, error %v", acquired, err)
+	}
+	if !bytes.Equal(key.ResponseBody, body) {
+		t.Fatalf("replayed body: got %s, want %s", key.ResponseBody, body)
+	}
+}
+
+func TestUserEraseDeletesIdempotentResponses(t *testing.T) {
+	db := newTestDB(t)
+	ctx, _ := newTestTenants(t, db)
+	dao := NewIdempotencyKeyDAO(db.Logger, db)
+
+	user := insertTestUser(t, ctx, db, randomPassport(t))
+	other := insertTestUser(t, ctx, db, randomPassport(t))
+	scope := fmt.Sprintf("test:%d", time.Now().UnixNano())
+
+	acquireTestIdempotencyKey(t, ctx, dao, scope, "user")
+	if err := dao.Complete(ctx, scope, "user", CompleteIdempotencyKeyDTO{StatusCode: 201, ResponseBody: []byte("{}"), Users: []model.ID{user}}); err != nil {
+		t.Fatalf("complete idempotency key: %v", err)
+	}
+	acquireTestIdempotencyKey(t, ctx, dao, scope, "other")
+	if err := dao.Complete(ctx, scope, "other", CompleteIdempotencyKeyDTO{StatusCode: 201, ResponseBody: []byte("{}"), Users: []model.ID{other}}); err != nil {
+		t.Fatalf("complete idempotency key: %v", err)
+	}
+
+	if _, err := NewUserDAO(db.Logger, db).Erase(ctx, user); err != nil {
+		t.Fatalf("erase user: %v", err)
+	}
+
+	tenant, _ := TenantFromContext(ctx)
+	if _, err := dao.get(ctx, tenant, scope, "user"); !errors.Is(err, model.ErrNotFound) {
+		t.Fatalf("response of erased user: got error %v, want %v", err, model.ErrNotFound)
+	}
+	if _, err := dao.get(ctx, tenant, scope, "other"); err != nil {
+		t.Fatalf("response of other user was deleted: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+type LoginCodeDAO struct {
+	Logger *slog.Logger
+	*DB
+}
+
+func NewLoginCodeDAO(logger *slog.Logger, db *DB) *LoginCodeDAO {
+	return &LoginCodeDAO{
+		Logger: logger.With("dao", "loginCode"),
+		DB:     db,
+	}
+}
+
+type InsertLoginCodeDTO struct {
+	Organization model.ID
+	User         model.ID
+	CodeHash     string
+	ExpiresAt    time.Time
+}
+
+func (dao *LoginCodeDAO) Insert(ctx context.Context, dto InsertLoginCodeDTO) (model.ID, error) {
+	logger := dao.Logger.With("query", "insert")
+
+	query, args, err := dao.Builder.
+		Insert("login_codes").
+		Columns("organization_id", "user_id", "code_hash", "expires_at").
+		Values(dto.Organization, dto.User, dto.CodeHash, dto.ExpiresAt).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var id model.ID
+	row := dao.QueryRowxContext(ctx, query, args...)
+	if err := row.Scan(&id); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		if IsUniqueViolation(err) {
+			return 0, model.NewError("login code", model.ErrExists)
+		}
+
+		return 0, err
+	}
+
+	logger.Debug("success query execute", "insertId", id)
+
+	return id, nil
+}
+
+// Consume marks unused and not expired code as used and returns it.
+func (dao *LoginCodeDAO) Consume(ctx context.Context, codeHash string) (model.LoginCode, error) {
+	logger := dao.Logger.With("query", "consume")
+
+	now := time.Now()
+	query, args, err := dao.Builder.
+		Update("login_codes").
+		Set("used_at", now).
+		Where(squirrel.Eq{"code_hash": codeHash, "used_at": nil}).
+		Where(squirrel.Gt{"expires_at": now}).
+		Suffix("RETURNING *").
+		ToSql()
+	if err != nil {
+		return model.LoginCode{}, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var code model.LoginCode
+	row := dao.QueryRowxContext(ctx, query, args...)
+	if err := row.StructScan(&code); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		if IsNoRows(err) {
+			return model.LoginCode{}, model.NewError("login code", model.ErrNotFound)
+		}
+
+		return model.LoginCode{}, err
+	}
+
+	logger.Debug("success query execute", "loginCodeId", code.ID)
+
+	return code, nil
+}
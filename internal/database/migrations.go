@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/protomem/time-tracker/assets"
+)
+
+var (
+	ErrNotMigrated   = errors.New("database is not migrated")
+	ErrDirtyMigrated = errors.New("database migration is dirty")
+)
+
+// LatestMigration returns version of the last embedded migration.
+func LatestMigration() (uint, error) {
+	driver, err := iofs.New(assets.EmbeddedFiles, "migrations")
+	if err != nil {
+		return 0, err
+	}
+	defer driver.Close()
+
+	version, err := driver.First()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		next, err := driver.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// CheckMigration reports an error if the database schema is not at the latest embedded migration.
+func (db *DB) CheckMigration(ctx context.Context) error {
+	expected, err := LatestMigration()
+	if err != nil {
+		return err
+	}
+
+	var (
+		version uint
+		dirty   bool
+	)
+	if err := db.QueryRowxContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty); err != nil {
+		if IsNoRows(err) {
+			return ErrNotMigrated
+		}
+		return err
+	}
+
+	if dirty {
+		return fmt.Errorf("%w: version %d", ErrDirtyMigrated, version)
+	}
+	if version != expected {
+		return fmt.Errorf("database migration version %d, expected %d", version, expected)
+	}
+
+	return nil
+}
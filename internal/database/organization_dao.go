@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+type OrganizationDAO struct {
+	Logger *slog.Logger
+	*DB
+}
+
+func NewOrganizationDAO(logger *slog.Logger, db *DB) *OrganizationDAO {
+	return &OrganizationDAO{
+		Logger: logger.With("dao", "organization"),
+		DB:     db,
+	}
+}
+
+func (dao *OrganizationDAO) Find(ctx context.Context, opts FindOptions) ([]model.Organization, error) {
+	logger := dao.Logger.With("query", "find")
+
+	query, args, err := dao.Builder.
+		Select("*").
+		From("organizations").
+		OrderBy("id ASC").
+		Limit(opts.Limit).
+		Offset(opts.Offset).
+		ToSql()
+	if err != nil {
+		return []model.Organization{}, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	organizations := make([]model.Organization, 0, opts.Limit)
+	if err := dao.SelectContext(ctx, &organizations, query, args...); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return []model.Organization{}, err
+	}
+
+	logger.Debug("success query execute", "countOrganizations", len(organizations))
+
+	return organizations, nil
+}
+
+func (dao *OrganizationDAO) Get(ctx context.Context, id model.ID) (model.Organization, error) {
+	logger := dao.Logger.With("query", "get")
+
+	query, args, err := dao.Builder.
+		Select("*").
+		From("organizations").
+		Where(squirrel.Eq{"id": id}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return model.Organization{}, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var organization model.Organization
+	row := dao.QueryRowxContext(ctx, query, args...)
+	if err := row.StructScan(&organization); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		if IsNoRows(err) {
+			return model.Organization{}, model.NewError("organization", model.ErrNotFound)
+		}
+
+		return model.Organization{}, err
+	}
+
+	logger.Debug("success query execute", "organization", organization)
+
+	return organization, nil
+}
+
+func (dao *OrganizationDAO) Insert(ctx context.Context, name string) (model.ID, error) {
+	logger := dao.Logger.With("query", "insert")
+
+	query, args, err := dao.Builder.
+		Insert("organizations").
+		Columns("name").
+		Values(name).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var id model.ID
+	row := dao.QueryRowxContext(ctx, query, args...)
+	if err := row.Scan(&id); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		if IsUniqueViolation(err) {
+			return 0, model.NewError("organization", model.ErrExists)
+		}
+
+		return 0, err
+	}
+
+	logger.Debug("success query execute", "insertId", id)
+
+	return id, nil
+}
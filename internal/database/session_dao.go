@@ -3,9 +3,11 @@ package database
 import (
 	"context"
 	"log/slog"
+	"slices"
 	"time"
 
 	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
 	"github.com/protomem/time-tracker/internal/model"
 )
 
@@ -27,10 +29,19 @@ type SessionTimelineOptions struct {
 }
 
 func (dao *SessionDAO) FindByUser(ctx context.Context, user model.ID, opts SessionTimelineOptions) ([]model.Session, error) {
+	return dao.FindByUsers(ctx, []model.ID{user}, opts)
+}
+
+func (dao *SessionDAO) FindByUsers(ctx context.Context, users []model.ID, opts SessionTimelineOptions) ([]model.Session, error) {
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return []model.Session{}, err
+	}
+
 	stmt := dao.Builder.
 		Select("*").
 		From("sessions").
-		Where(squirrel.Eq{"user_id": user}).
+		Where(squirrel.Eq{"user_id": users, "organization_id": tenant}).
 		OrderBy("sess_begin DESC")
 
 	if opts.After != nil {
@@ -61,10 +72,15 @@ func (dao *SessionDAO) FindByUser(ctx context.Context, user model.ID, opts Sessi
 func (dao *SessionDAO) Get(ctx context.Context, id model.ID) (model.Session, error) {
 	logger := dao.Logger.With("query", "get")
 
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return model.Session{}, err
+	}
+
 	query, args, err := dao.Builder.
 		Select("*").
 		From("sessions").
-		Where(squirrel.Eq{"id": id}).
+		Where(squirrel.Eq{"id": id, "organization_id": tenant}).
 		Limit(1).
 		ToSql()
 	if err != nil {
@@ -93,11 +109,16 @@ func (dao *SessionDAO) Get(ctx context.Context, id model.ID) (model.Session, err
 func (dao *SessionDAO) LastByTaskAndUser(ctx context.Context, task, user model.ID) (model.Session, error) {
 	logger := dao.Logger.With("query", "lastByTaskAndUser")
 
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return model.Session{}, err
+	}
+
 	query, args, err := dao.Builder.
 		Select("*").
 		From("sessions").
 		Where(squirrel.Eq{"task_id": task}).
-		Where(squirrel.Eq{"user_id": user}).
+		Where(squirrel.Eq{"user_id": user, "organization_id": tenant}).
 		OrderBy("sess_begin DESC").
 		Limit(1).
 		ToSql()
@@ -141,11 +162,16 @@ func NewInsertSessionDTO(user model.ID, task model.ID) InsertSessionDTO {
 func (dao *SessionDAO) Insert(ctx context.Context, dto InsertSessionDTO) (model.ID, error) {
 	logger := dao.Logger.With("query", "insert")
 
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
 	query, args, err := dao.Builder.
 		Insert("sessions").
-		Columns("user_id", "task_id", "sess_begin").
-		Values(dto.User, dto.Task, dto.Begin).
-		Suffix("RETURNING id").
+		Columns("organization_id", "user_id", "task_id", "sess_begin").
+		Values(tenant, dto.User, dto.Task, dto.Begin).
+		Suffix("RETURNING *").
 		ToSql()
 	if err != nil {
 		return 0, err
@@ -153,9 +179,14 @@ func (dao *SessionDAO) Insert(ctx context.Context, dto InsertSessionDTO) (model.
 
 	logger.Debug("build query", "sql", query, "args", args)
 
-	var id model.ID
-	row := dao.QueryRowxContext(ctx, query, args...)
-	if err := row.Scan(&id); err != nil {
+	var session model.Session
+	if err := dao.withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := tx.QueryRowxContext(ctx, query, args...).StructScan(&session); err != nil {
+			return err
+		}
+
+		return dao.insertAuditEntry(ctx, tx, logger, AuditEntitySession, session.ID, model.AuditCreate, nil, session)
+	}); err != nil {
 		logger.Warn("failed query execute", "error", err)
 
 		if IsUniqueViolation(err) {
@@ -165,25 +196,34 @@ func (dao *SessionDAO) Insert(ctx context.Context, dto InsertSessionDTO) (model.
 		return 0, err
 	}
 
-	logger.Debug("success query execute", "insertId", id)
+	logger.Debug("success query execute", "insertId", session.ID)
 
-	return id, nil
+	return session.ID, nil
 }
 
 type UpdateSessionDTO struct {
 	End time.Time
+
+	// IfMatch applies the update only to one of the versions, nil allows any version.
+	IfMatch []int64
 }
 
 func (dao *SessionDAO) Update(ctx context.Context, id model.ID, dto UpdateSessionDTO) error {
 	logger := dao.Logger.With("query", "update")
 
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
 	query, args, err := dao.Builder.
 		Update("sessions").
 		SetMap(map[string]any{
 			"updated_at": time.Now(),
 			"sess_end":   dto.End,
 		}).
-		Where(squirrel.Eq{"id": id}).
+		Where(squirrel.Eq{"id": id, "organization_id": tenant}).
+		Suffix("RETURNING *").
 		ToSql()
 	if err != nil {
 		return err
@@ -191,7 +231,28 @@ func (dao *SessionDAO) Update(ctx context.Context, id model.ID, dto UpdateSessio
 
 	logger.Debug("build query", "sql", query, "args", args)
 
-	if _, err = dao.ExecContext(ctx, query, args...); err != nil {
+	if err := dao.withTx(ctx, func(tx *sqlx.Tx) error {
+		before, err := dao.getForUpdate(ctx, tx, tenant, id)
+		if err != nil {
+			return err
+		}
+		if dto.IfMatch != nil && !slices.Contains(dto.IfMatch, before.Version) {
+			return model.NewError("session", model.ErrVersionMismatch)
+		}
+
+		var after model.Session
+		if err := tx.QueryRowxContext(ctx, query, args...).StructScan(&after); err != nil {
+			return err
+		}
+
+		return dao.insertAuditEntry(ctx, tx, logger, AuditEntitySession, id, model.AuditUpdate, before, after)
+	}); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		if IsNoRows(err) {
+			return model.NewError("session", model.ErrNotFound)
+		}
+
 		return err
 	}
 
@@ -199,3 +260,68 @@ func (dao *SessionDAO) Update(ctx context.Context, id model.ID, dto UpdateSessio
 
 	return nil
 }
+
+// getForUpdate locks the session row until the end of the transaction.
+func (dao *SessionDAO) getForUpdate(ctx context.Context, tx *sqlx.Tx, tenant model.ID, id model.ID) (model.Session, error) {
+	query, args, err := dao.Builder.
+		Select("*").
+		From("sessions").
+		Where(squirrel.Eq{"id": id, "organization_id": tenant}).
+		Suffix("FOR UPDATE").
+		ToSql()
+	if err != nil {
+		return model.Session{}, err
+	}
+
+	var session model.Session
+	if err := tx.QueryRowxContext(ctx, query, args...).StructScan(&session); err != nil {
+		return model.Session{}, err
+	}
+
+	return session, nil
+}
+
+// CountOpen returns number of not ended sessions per organization.
+// It is not tenant scoped and is used for metrics.
+func (dao *SessionDAO) CountOpen(ctx context.Context) (map[model.ID]int, error) {
+	logger := dao.Logger.With("query", "countOpen")
+
+	query, args, err := dao.Builder.
+		Select("organization_id", "count(*)").
+		From("sessions").
+		Where(squirrel.Eq{"sess_end": nil}).
+		GroupBy("organization_id").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	rows, err := dao.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[model.ID]int)
+	for rows.Next() {
+		var (
+			organization model.ID
+			count        int
+		)
+		if err := rows.Scan(&organization, &count); err != nil {
+			return nil, err
+		}
+		counts[organization] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	logger.Debug("success query execute", "countOrganizations", len(counts))
+
+	return counts, nil
+}
@@ -0,0 +1,211 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+type SessionSummaryDAO struct {
+	Logger *slog.Logger
+	*DB
+}
+
+func NewSessionSummaryDAO(logger *slog.Logger, db *DB) *SessionSummaryDAO {
+	return &SessionSummaryDAO{
+		Logger: logger.With("dao", "sessionSummary"),
+		DB:     db,
+	}
+}
+
+// FindByUsers returns summaries of the months lying entirely within the period,
+// archived data is accounted with a month precision and months covered partially are skipped.
+func (dao *SessionSummaryDAO) FindByUsers(ctx context.Context, users []model.ID, opts SessionTimelineOptions) ([]model.SessionSummary, error) {
+	logger := dao.Logger.With("query", "findByUsers")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return []model.SessionSummary{}, err
+	}
+
+	stmt := dao.Builder.
+		Select("*").
+		From("session_summaries").
+		Where(squirrel.Eq{"user_id": users, "organization_id": tenant}).
+		OrderBy("month DESC", "task_id ASC")
+
+	if opts.After != nil {
+		month := model.MonthStart(*opts.After)
+		if month.Before(*opts.After) {
+			month = month.AddDate(0, 1, 0)
+		}
+		stmt = stmt.Where(squirrel.GtOrEq{"month": month})
+	}
+	if opts.Before != nil {
+		stmt = stmt.Where(squirrel.Lt{"month": model.MonthStart(*opts.Before)})
+	}
+
+	query, args, err := stmt.ToSql()
+	if err != nil {
+		return []model.SessionSummary{}, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	summaries := make([]model.SessionSummary, 0)
+	if err := dao.SelectContext(ctx, &summaries, query, args...); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return []model.SessionSummary{}, err
+	}
+
+	logger.Debug("success query execute", "countSummaries", len(summaries))
+
+	return summaries, nil
+}
+
+type sessionSummaryKey struct {
+	Organization model.ID
+	User         model.ID
+	Task         model.ID
+	Month        time.Time
+}
+
+type sessionSummaryTotal struct {
+	SessionsCount int
+	Duration      time.Duration
+}
+
+// Archive moves sessions ended before the cutoff into monthly summaries in batches.
+// A session spanning several months is split between them and counted in the month it began.
+// It is not tenant scoped and is run by the retention job,
+// each batch is audited with one entry per organization listing the archived sessions.
+func (dao *SessionSummaryDAO) Archive(ctx context.Context, cutoff time.Time, batchSize uint64) (int, error) {
+	logger := dao.Logger.With("query", "archive")
+
+	query, args, err := dao.Builder.
+		Select("*").
+		From("sessions").
+		Where(squirrel.Lt{"sess_end": cutoff}).
+		OrderBy("id ASC").
+		Limit(batchSize).
+		Suffix("FOR UPDATE SKIP LOCKED").
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	total := 0
+	for {
+		count := 0
+		if err := dao.withTx(ctx, func(tx *sqlx.Tx) error {
+			var sessions []model.Session
+			if err := tx.SelectContext(ctx, &sessions, query, args...); err != nil {
+				return err
+			}
+			count = len(sessions)
+
+			if count == 0 {
+				return nil
+			}
+
+			return dao.archiveSessions(ctx, tx, logger, cutoff, sessions)
+		}); err != nil {
+			logger.Warn("failed query execute", "error", err)
+
+			return total, err
+		}
+
+		total += count
+		if uint64(count) < batchSize {
+			break
+		}
+	}
+
+	logger.Debug("success query execute", "countSessions", total)
+
+	return total, nil
+}
+
+// sessionArchiveAudit is the state of the archived batch recorded in the audit log.
+type sessionArchiveAudit struct {
+	Cutoff     time.Time  `json:"cutoff"`
+	SessionIDs []model.ID `json:"sessionIds"`
+}
+
+func (dao *SessionSummaryDAO) archiveSessions(
+	ctx context.Context, tx *sqlx.Tx, logger *slog.Logger,
+	cutoff time.Time, sessions []model.Session,
+) error {
+	totals := make(map[sessionSummaryKey]sessionSummaryTotal)
+	ids := make([]model.ID, 0, len(sessions))
+	tenantIDs := make(map[model.ID][]model.ID)
+	tenants := make([]model.ID, 0)
+
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+
+		if _, ok := tenantIDs[session.Organization]; !ok {
+			tenants = append(tenants, session.Organization)
+		}
+		tenantIDs[session.Organization] = append(tenantIDs[session.Organization], session.ID)
+
+		for begin := session.Begin.UTC(); begin.Before(*session.End); {
+			month := model.MonthStart(begin)
+			end := month.AddDate(0, 1, 0)
+			if session.End.Before(end) {
+				end = *session.End
+			}
+
+			key := sessionSummaryKey{session.Organization, session.User, session.Task, month}
+			sum := totals[key]
+			if begin.Equal(session.Begin) {
+				sum.SessionsCount++
+			}
+			sum.Duration += end.Sub(begin)
+			totals[key] = sum
+
+			begin = end
+		}
+	}
+
+	if len(totals) != 0 {
+		stmt := dao.Builder.
+			Insert("session_summaries").
+			Columns("organization_id", "user_id", "task_id", "month", "sessions_count", "duration_ms").
+			Suffix(`ON CONFLICT ON CONSTRAINT unique_session_summary DO UPDATE SET
+				sessions_count = session_summaries.sessions_count + EXCLUDED.sessions_count,
+				duration_ms = session_summaries.duration_ms + EXCLUDED.duration_ms,
+				updated_at = now()`)
+		for key, sum := range totals {
+			stmt = stmt.Values(key.Organization, key.User, key.Task, key.Month, sum.SessionsCount, sum.Duration.Milliseconds())
+		}
+
+		if err := execQuery(ctx, tx, logger, stmt); err != nil {
+			return err
+		}
+	}
+
+	if err := execQuery(ctx, tx, logger, dao.Builder.
+		Delete("sessions").
+		Where(squirrel.Eq{"id": ids})); err != nil {
+		return err
+	}
+
+	// Entry of the batch is identified by its first session, sessions are archived in order of ID.
+	for _, tenant := range tenants {
+		archived := tenantIDs[tenant]
+		state := sessionArchiveAudit{Cutoff: cutoff, SessionIDs: archived}
+		if err := dao.insertAuditEntry(WithTenant(ctx, tenant), tx, logger, AuditEntitySessionArchive, archived[0], model.AuditDelete, state, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,365 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+type TeamDAO struct {
+	Logger *slog.Logger
+	*DB
+}
+
+func NewTeamDAO(logger *slog.Logger, db *DB) *TeamDAO {
+	return &TeamDAO{
+		Logger: logger.With("dao", "team"),
+		DB:     db,
+	}
+}
+
+type FindTeamFilter struct {
+	// ManagedBy limits teams to the teams the user manages.
+	ManagedBy *model.ID
+}
+
+func (dao *TeamDAO) Find(ctx context.Context, filter FindTeamFilter, opts FindOptions) ([]model.Team, error) {
+	logger := dao.Logger.With("query", "find")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return []model.Team{}, err
+	}
+
+	var managed squirrel.Sqlizer = squirrel.Eq{}
+	if filter.ManagedBy != nil {
+		managed = squirrel.Expr(
+			"id IN (SELECT team_id FROM team_members WHERE user_id = ? AND role = ?)",
+			*filter.ManagedBy, model.TeamRoleManager,
+		)
+	}
+
+	query, args, err := dao.Builder.
+		Select("*").
+		From("teams").
+		Where(squirrel.Eq{"organization_id": tenant}).
+		Where(managed).
+		OrderBy("name ASC").
+		Limit(opts.Limit).
+		Offset(opts.Offset).
+		ToSql()
+	if err != nil {
+		return []model.Team{}, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	teams := make([]model.Team, 0, opts.Limit)
+	if err := dao.SelectContext(ctx, &teams, query, args...); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return []model.Team{}, err
+	}
+
+	logger.Debug("success query execute", "countTeams", len(teams))
+
+	return teams, nil
+}
+
+func (dao *TeamDAO) Get(ctx context.Context, id model.ID) (model.Team, error) {
+	logger := dao.Logger.With("query", "get")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return model.Team{}, err
+	}
+
+	query, args, err := dao.Builder.
+		Select("*").
+		From("teams").
+		Where(squirrel.Eq{"id": id, "organization_id": tenant}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return model.Team{}, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var team model.Team
+	row := dao.QueryRowxContext(ctx, query, args...)
+	if err := row.StructScan(&team); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		if IsNoRows(err) {
+			return model.Team{}, model.NewError("team", model.ErrNotFound)
+		}
+
+		return model.Team{}, err
+	}
+
+	logger.Debug("success query execute", "team", team)
+
+	return team, nil
+}
+
+func (dao *TeamDAO) Insert(ctx context.Context, name string) (model.ID, error) {
+	logger := dao.Logger.With("query", "insert")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query, args, err := dao.Builder.
+		Insert("teams").
+		Columns("organization_id", "name").
+		Values(tenant, name).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var id model.ID
+	row := dao.QueryRowxContext(ctx, query, args...)
+	if err := row.Scan(&id); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		if IsUniqueViolation(err) {
+			return 0, model.NewError("team", model.ErrExists)
+		}
+
+		return 0, err
+	}
+
+	logger.Debug("success query execute", "insertId", id)
+
+	return id, nil
+}
+
+func (dao *TeamDAO) Delete(ctx context.Context, id model.ID) error {
+	logger := dao.Logger.With("query", "delete")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := dao.Builder.
+		Delete("teams").
+		Where(squirrel.Eq{"id": id, "organization_id": tenant}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	if _, err = dao.ExecContext(ctx, query, args...); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return err
+	}
+
+	logger.Debug("success query execute", "deleteId", id)
+
+	return nil
+}
+
+func (dao *TeamDAO) FindMembers(ctx context.Context, team model.ID) ([]model.TeamMember, error) {
+	logger := dao.Logger.With("query", "findMembers")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return []model.TeamMember{}, err
+	}
+
+	query, args, err := dao.Builder.
+		Select("team_members.*").
+		From("team_members").
+		Join("teams ON teams.id = team_members.team_id").
+		Where(squirrel.Eq{"team_members.team_id": team, "teams.organization_id": tenant}).
+		OrderBy("team_members.role ASC", "team_members.user_id ASC").
+		ToSql()
+	if err != nil {
+		return []model.TeamMember{}, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	members := make([]model.TeamMember, 0)
+	if err := dao.SelectContext(ctx, &members, query, args...); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return []model.TeamMember{}, err
+	}
+
+	logger.Debug("success query execute", "countMembers", len(members))
+
+	return members, nil
+}
+
+// SetMember adds the user to the team or changes the role of the existing member.
+// Both the team and the user must belong to the tenant.
+func (dao *TeamDAO) SetMember(ctx context.Context, team, user model.ID, role model.TeamRole) error {
+	logger := dao.Logger.With("query", "setMember")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := dao.Builder.
+		Insert("team_members").
+		Columns("team_id", "user_id", "role").
+		Select(dao.Builder.
+			Select("teams.id", "users.id").
+			Column("?", role).
+			From("teams").
+			Join("users ON users.organization_id = teams.organization_id").
+			Where(squirrel.Eq{"teams.id": team, "users.id": user, "teams.organization_id": tenant}),
+		).
+		Suffix("ON CONFLICT (team_id, user_id) DO UPDATE SET role = EXCLUDED.role, updated_at = now()").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	res, err := dao.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return err
+	}
+
+	if count, err := res.RowsAffected(); err == nil && count == 0 {
+		return model.NewError("team member", model.ErrNotFound)
+	}
+
+	logger.Debug("success query execute", "teamId", team, "userId", user)
+
+	return nil
+}
+
+func (dao *TeamDAO) RemoveMember(ctx context.Context, team, user model.ID) error {
+	logger := dao.Logger.With("query", "removeMember")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := dao.Builder.
+		Delete("team_members").
+		Where(squirrel.Eq{"team_id": team, "user_id": user}).
+		Where(squirrel.Expr("team_id IN (SELECT id FROM teams WHERE organization_id = ?)", tenant)).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	res, err := dao.ExecContext(ctx, query, args...)
+	if err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return err
+	}
+
+	if count, err := res.RowsAffected(); err == nil && count == 0 {
+		return model.NewError("team member", model.ErrNotFound)
+	}
+
+	logger.Debug("success query execute", "teamId", team, "userId", user)
+
+	return nil
+}
+
+// IsManagerOf reports whether the manager manages any team of the tenant the user is a member of.
+func (dao *TeamDAO) IsManagerOf(ctx context.Context, manager, user model.ID) (bool, error) {
+	logger := dao.Logger.With("query", "isManagerOf")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	query, args, err := dao.Builder.
+		Select("1").
+		From("team_members AS managers").
+		Join("team_members AS members ON members.team_id = managers.team_id").
+		Join("teams ON teams.id = managers.team_id").
+		Where(squirrel.Eq{
+			"managers.user_id":      manager,
+			"managers.role":         model.TeamRoleManager,
+			"members.user_id":       user,
+			"teams.organization_id": tenant,
+		}).
+		Prefix("SELECT EXISTS (").
+		Suffix(")").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var exists bool
+	if err := dao.QueryRowxContext(ctx, query, args...).Scan(&exists); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return false, err
+	}
+
+	logger.Debug("success query execute", "exists", exists)
+
+	return exists, nil
+}
+
+// IsTeamManager reports whether the user manages the team of the tenant.
+func (dao *TeamDAO) IsTeamManager(ctx context.Context, team, user model.ID) (bool, error) {
+	logger := dao.Logger.With("query", "isTeamManager")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	query, args, err := dao.Builder.
+		Select("1").
+		From("team_members").
+		Join("teams ON teams.id = team_members.team_id").
+		Where(squirrel.Eq{
+			"team_members.team_id":  team,
+			"team_members.user_id":  user,
+			"team_members.role":     model.TeamRoleManager,
+			"teams.organization_id": tenant,
+		}).
+		Prefix("SELECT EXISTS (").
+		Suffix(")").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	var exists bool
+	if err := dao.QueryRowxContext(ctx, query, args...).Scan(&exists); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return false, err
+	}
+
+	logger.Debug("success query execute", "exists", exists)
+
+	return exists, nil
+}
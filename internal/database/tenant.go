@@ -0,0 +1,31 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/protomem/time-tracker/internal/ctxstore"
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+const _tenantKey = ctxstore.Key("tenant")
+
+// ErrTenantRequired is returned by tenant scoped queries when the context has no organization.
+var ErrTenantRequired = errors.New("tenant is required")
+
+// WithTenant scopes queries of tenant aware DAOs to the organization.
+func WithTenant(ctx context.Context, organization model.ID) context.Context {
+	return ctxstore.With(ctx, _tenantKey, organization)
+}
+
+func TenantFromContext(ctx context.Context) (model.ID, bool) {
+	return ctxstore.From[model.ID](ctx, _tenantKey)
+}
+
+func tenantFromContext(ctx context.Context) (model.ID, error) {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return 0, ErrTenantRequired
+	}
+	return tenant, nil
+}
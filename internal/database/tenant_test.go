@@ -0,0 +1,302 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/protomem/time-tracker/internal/config"
+	"github.com/protomem/time-tracker/internal/model"
+	"github.com/protomem/time-tracker/internal/pii"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Tenant tests run against a dedicated database, they are skipped unless TEST_DB_DSN is set,
+// e.g. TEST_DB_DSN="admin:123456789@localhost:5432/time_tracker_test?sslmode=disable" go test ./internal/database.
+// Created organizations are not removed, the audit log referencing them is append-only.
+
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	db, err := New(logger, config.DB{
+		DSN:            dsn,
+		Automigrate:    true,
+		ConnectTimeout: 5 * time.Second,
+		MaxOpenConns:   5,
+		MaxIdleConns:   5,
+	}, noop.NewTracerProvider())
+	if err != nil {
+		t.Fatalf("connect to database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	protector, err := pii.NewProtector(map[uint][]byte{1: randomBytes(t, 32)}, 1, randomBytes(t, 32))
+	if err != nil {
+		t.Fatalf("create protector: %v", err)
+	}
+	db.PII = protector
+
+	return db
+}
+
+// newTestTenants creates two organizations and returns contexts scoped to them.
+func newTestTenants(t *testing.T, db *DB) (context.Context, context.Context) {
+	t.Helper()
+
+	dao := NewOrganizationDAO(db.Logger, db)
+	suffix := time.Now().UnixNano()
+
+	orgA, err := dao.Insert(context.Background(), fmt.Sprintf("tenant-test-a-%d", suffix))
+	if err != nil {
+		t.Fatalf("insert organization: %v", err)
+	}
+	orgB, err := dao.Insert(context.Background(), fmt.Sprintf("tenant-test-b-%d", suffix))
+	if err != nil {
+		t.Fatalf("insert organization: %v", err)
+	}
+
+	return WithTenant(context.Background(), orgA), WithTenant(context.Background(), orgB)
+}
+
+func insertTestUser(t *testing.T, ctx context.Context, db *DB, passport model.Passport) model.ID {
+	t.Helper()
+
+	id, err := NewUserDAO(db.Logger, db).Insert(ctx, NewInsertUserDTO("Ivan", "Ivanov", passport, "Moscow"))
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	return id
+}
+
+func randomPassport(t *testing.T) model.Passport {
+	t.Helper()
+
+	return model.Passport{
+		Serie:  randomDigits(t, 4),
+		Number: randomDigits(t, 6),
+	}
+}
+
+func randomDigits(t *testing.T, n int) string {
+	t.Helper()
+
+	digits := make([]byte, n)
+	for i := range digits {
+		d, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			t.Fatalf("random digit: %v", err)
+		}
+		digits[i] = byte('0' + d.Int64())
+	}
+
+	return string(digits)
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("random bytes: %v", err)
+	}
+
+	return b
+}
+
+func TestUserDAOTenantIsolation(t *testing.T) {
+	db := newTestDB(t)
+	ctxA, ctxB := newTestTenants(t, db)
+	dao := NewUserDAO(db.Logger, db)
+
+	userA := insertTestUser(t, ctxA, db, randomPassport(t))
+	userB := insertTestUser(t, ctxB, db, randomPassport(t))
+
+	t.Run("Get", func(t *testing.T) {
+		if _, err := dao.Get(ctxA, userB); !errors.Is(err, model.ErrNotFound) {
+			t.Fatalf("get user of other tenant: got error %v, want %v", err, model.ErrNotFound)
+		}
+		if _, err := dao.Get(ctxA, userA); err != nil {
+			t.Fatalf("get user of own tenant: %v", err)
+		}
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		users, err := dao.Find(ctxA, FindUserFilter{}, FindOptions{Limit: 10000})
+		if err != nil {
+			t.Fatalf("find users: %v", err)
+		}
+
+		foundA := false
+		for _, user := range users {
+			if user.ID == userB {
+				t.Fatalf("find users returned user %d of other tenant", userB)
+			}
+			if user.ID == userA {
+				foundA = true
+			}
+		}
+		if !foundA {
+			t.Fatalf("find users did not return user %d of own tenant", userA)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		name := "Changed"
+		err := dao.Update(ctxA, userB, UpdateUserDTO{Name: &name})
+		if !errors.Is(err, model.ErrNotFound) {
+			t.Fatalf("update user of other tenant: got error %v, want %v", err, model.ErrNotFound)
+		}
+
+		user, err := dao.Get(ctxB, userB)
+		if err != nil {
+			t.Fatalf("get user: %v", err)
+		}
+		if user.Name == name {
+			t.Fatalf("user of other tenant was updated")
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := dao.Delete(ctxA, userB, nil); err != nil {
+			t.Fatalf("delete user of other tenant: %v", err)
+		}
+
+		if _, err := dao.Get(ctxB, userB); err != nil {
+			t.Fatalf("user of other tenant was deleted: %v", err)
+		}
+	})
+}
+
+func TestSessionDAOTenantIsolation(t *testing.T) {
+	db := newTestDB(t)
+	ctxA, ctxB := newTestTenants(t, db)
+	dao := NewSessionDAO(db.Logger, db)
+
+	const task model.ID = 1
+
+	userB := insertTestUser(t, ctxB, db, randomPassport(t))
+	sessionB, err := dao.Insert(ctxB, NewInsertSessionDTO(userB, task))
+	if err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		if _, err := dao.Get(ctxA, sessionB); !errors.Is(err, model.ErrNotFound) {
+			t.Fatalf("get session of other tenant: got error %v, want %v", err, model.ErrNotFound)
+		}
+	})
+
+	t.Run("FindByUsers", func(t *testing.T) {
+		sessions, err := dao.FindByUsers(ctxA, []model.ID{userB}, SessionTimelineOptions{})
+		if err != nil {
+			t.Fatalf("find sessions: %v", err)
+		}
+		if len(sessions) != 0 {
+			t.Fatalf("find sessions returned %d sessions of other tenant", len(sessions))
+		}
+	})
+
+	t.Run("LastByTaskAndUser", func(t *testing.T) {
+		if _, err := dao.LastByTaskAndUser(ctxA, task, userB); !errors.Is(err, model.ErrNotFound) {
+			t.Fatalf("last session of other tenant: got error %v, want %v", err, model.ErrNotFound)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		err := dao.Update(ctxA, sessionB, UpdateSessionDTO{End: time.Now()})
+		if !errors.Is(err, model.ErrNotFound) {
+			t.Fatalf("update session of other tenant: got error %v, want %v", err, model.ErrNotFound)
+		}
+
+		session, err := dao.Get(ctxB, sessionB)
+		if err != nil {
+			t.Fatalf("get session: %v", err)
+		}
+		if session.End != nil {
+			t.Fatalf("session of other tenant was stopped")
+		}
+	})
+
+	t.Run("CountOpen", func(t *testing.T) {
+		counts, err := dao.CountOpen(ctxA)
+		if err != nil {
+			t.Fatalf("count open sessions: %v", err)
+		}
+
+		tenantA, _ := TenantFromContext(ctxA)
+		if counts[tenantA] != 0 {
+			t.Fatalf("open sessions of other tenant are counted for tenant %d", tenantA)
+		}
+	})
+}
+
+func TestUniquePassportPerTenant(t *testing.T) {
+	db := newTestDB(t)
+	ctxA, ctxB := newTestTenants(t, db)
+	dao := NewUserDAO(db.Logger, db)
+
+	passport := randomPassport(t)
+
+	insertTestUser(t, ctxA, db, passport)
+	insertTestUser(t, ctxB, db, passport)
+
+	_, err := dao.Insert(ctxA, NewInsertUserDTO("Petr", "Petrov", passport, "Kazan"))
+	if !errors.Is(err, model.ErrExists) {
+		t.Fatalf("insert duplicate passport in one tenant: got error %v, want %v", err, model.ErrExists)
+	}
+}
+
+func TestTeamDAOTenantIsolation(t *testing.T) {
+	db := newTestDB(t)
+	ctxA, ctxB := newTestTenants(t, db)
+	dao := NewTeamDAO(db.Logger, db)
+
+	userA := insertTestUser(t, ctxA, db, randomPassport(t))
+	userB := insertTestUser(t, ctxB, db, randomPassport(t))
+
+	teamB, err := dao.Insert(ctxB, "team")
+	if err != nil {
+		t.Fatalf("insert team: %v", err)
+	}
+
+	t.Run("SetMember", func(t *testing.T) {
+		if err := dao.SetMember(ctxA, teamB, userA, model.TeamRoleMember); !errors.Is(err, model.ErrNotFound) {
+			t.Fatalf("set member of team of other tenant: got error %v, want %v", err, model.ErrNotFound)
+		}
+		if err := dao.SetMember(ctxB, teamB, userA, model.TeamRoleMember); !errors.Is(err, model.ErrNotFound) {
+			t.Fatalf("set user of other tenant as member: got error %v, want %v", err, model.ErrNotFound)
+		}
+		if err := dao.SetMember(ctxB, teamB, userB, model.TeamRoleManager); err != nil {
+			t.Fatalf("set member of own tenant: %v", err)
+		}
+	})
+
+	t.Run("RemoveMember", func(t *testing.T) {
+		if err := dao.RemoveMember(ctxA, teamB, userB); !errors.Is(err, model.ErrNotFound) {
+			t.Fatalf("remove member of team of other tenant: got error %v, want %v", err, model.ErrNotFound)
+		}
+
+		members, err := dao.FindMembers(ctxB, teamB)
+		if err != nil {
+			t.Fatalf("find members: %v", err)
+		}
+		if len(members) != 1 {
+			t.Fatalf("member of other tenant was removed, got %d members", len(members))
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+type UserChangeDAO struct {
+	Logger *slog.Logger
+	*DB
+}
+
+func NewUserChangeDAO(logger *slog.Logger, db *DB) *UserChangeDAO {
+	return &UserChangeDAO{
+		Logger: logger.With("dao", "userChange"),
+		DB:     db,
+	}
+}
+
+func (dao *UserChangeDAO) FindByUser(ctx context.Context, user model.ID, opts FindOptions) ([]model.UserChange, error) {
+	logger := dao.Logger.With("query", "findByUser")
+
+	query, args, err := dao.Builder.
+		Select("*").
+		From("user_changes").
+		Where(squirrel.Eq{"user_id": user}).
+		OrderBy("created_at DESC", "id DESC").
+		Limit(opts.Limit).
+		Offset(opts.Offset).
+		ToSql()
+	if err != nil {
+		return []model.UserChange{}, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	changes := make([]model.UserChange, 0, opts.Limit)
+	if err := dao.SelectContext(ctx, &changes, query, args...); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return []model.UserChange{}, err
+	}
+
+	logger.Debug("success query execute", "countChanges", len(changes))
+
+	return changes, nil
+}
+
+type InsertUserChangeDTO struct {
+	Field    string
+	OldValue *string
+	NewValue *string
+}
+
+// insertUserChanges records the changes within the transaction of the user update.
+func (db *DB) insertUserChanges(
+	ctx context.Context, tx *sqlx.Tx, logger *slog.Logger,
+	user model.ID, dtos []InsertUserChangeDTO,
+) ([]model.UserChange, error) {
+	if len(dtos) == 0 {
+		return []model.UserChange{}, nil
+	}
+
+	stmt := db.Builder.
+		Insert("user_changes").
+		Columns("user_id", "field", "old_value", "new_value").
+		Suffix("RETURNING *")
+	for _, dto := range dtos {
+		stmt = stmt.Values(user, dto.Field, dto.OldValue, dto.NewValue)
+	}
+
+	query, args, err := stmt.ToSql()
+	if err != nil {
+		return []model.UserChange{}, err
+	}
+
+	logger.Debug("build query", "query", "insertUserChanges", "sql", query, "args", args)
+
+	changes := make([]model.UserChange, 0, len(dtos))
+	if err := sqlx.SelectContext(ctx, tx, &changes, query, args...); err != nil {
+		logger.Warn("failed query execute", "query", "insertUserChanges", "error", err)
+
+		return []model.UserChange{}, err
+	}
+
+	return changes, nil
+}
@@ -3,9 +3,11 @@ package database
 import (
 	"context"
 	"log/slog"
+	"slices"
 	"time"
 
 	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
 	"github.com/protomem/time-tracker/internal/model"
 )
 
@@ -25,15 +27,24 @@ type FindUserFilter struct {
 	Name           *string
 	Surname        *string
 	Patronymic     *string
-	PassportSerie  *int
-	PassportNumber *int
+	PassportSerie  *string
+	PassportNumber *string
 	Address        *string
+	Erased         *bool
+
+	// ManagedBy limits users to members of the teams the user manages.
+	ManagedBy *model.ID
 }
 
 func (dao *UserDAO) Find(ctx context.Context, filter FindUserFilter, opts FindOptions) ([]model.User, error) {
 	logger := dao.Logger.With("query", "find")
 
-	equals := squirrel.Eq{}
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return []model.User{}, err
+	}
+
+	equals := squirrel.Eq{"organization_id": tenant}
 	if filter.Name != nil {
 		equals["name"] = *filter.Name
 	}
@@ -43,23 +54,44 @@ func (dao *UserDAO) Find(ctx context.Context, filter FindUserFilter, opts FindOp
 	if filter.Patronymic != nil {
 		equals["patronymic"] = *filter.Patronymic
 	}
-	if filter.PassportSerie != nil {
-		equals["passport_serie"] = *filter.PassportSerie
-	}
-	if filter.PassportNumber != nil {
-		equals["passport_number"] = *filter.PassportNumber
-	}
 	if filter.Address != nil {
 		equals["address"] = *filter.Address
 	}
 
+	passportEquals, err := dao.passportIndexFilter(filter.PassportSerie, filter.PassportNumber)
+	if err != nil {
+		return []model.User{}, err
+	}
+
+	var erased squirrel.Sqlizer = squirrel.Eq{}
+	if filter.Erased != nil {
+		if *filter.Erased {
+			erased = squirrel.NotEq{"erased_at": nil}
+		} else {
+			erased = squirrel.Eq{"erased_at": nil}
+		}
+	}
+
+	var managed squirrel.Sqlizer = squirrel.Eq{}
+	if filter.ManagedBy != nil {
+		managed = squirrel.Expr(
+			"id IN (SELECT members.user_id FROM team_members AS members"+
+				" JOIN team_members AS managers ON managers.team_id = members.team_id"+
+				" WHERE managers.user_id = ? AND managers.role = ?)",
+			*filter.ManagedBy, model.TeamRoleManager,
+		)
+	}
+
 	query, args, err := dao.Builder.
 		Select("*").
 		From("users").
 		Where(equals).
+		Where(passportEquals).
+		Where(erased).
+		Where(managed).
 		Limit(uint64(opts.Limit)).
 		Offset(uint64(opts.Offset)).
-		OrderBy("created_at ASC").
+		OrderBy("created_at ASC", "id ASC").
 		ToSql()
 	if err != nil {
 		return []model.User{}, err
@@ -67,8 +99,8 @@ func (dao *UserDAO) Find(ctx context.Context, filter FindUserFilter, opts FindOp
 
 	logger.Debug("build query", "sql", query, "args", args)
 
-	users := make([]model.User, 0, opts.Limit)
-	if err := dao.SelectContext(ctx, &users, query, args...); err != nil {
+	rows := make([]userRow, 0, opts.Limit)
+	if err := dao.SelectContext(ctx, &rows, query, args...); err != nil {
 		if IsNoRows(err) {
 			logger.Debug("success query execute", "countUsers", 0)
 			return []model.User{}, nil
@@ -79,6 +111,11 @@ func (dao *UserDAO) Find(ctx context.Context, filter FindUserFilter, opts FindOp
 		return []model.User{}, err
 	}
 
+	users, err := dao.decodeUsers(rows)
+	if err != nil {
+		return []model.User{}, err
+	}
+
 	logger.Debug("success query execute", "countUsers", len(users))
 
 	return users, nil
@@ -87,10 +124,15 @@ func (dao *UserDAO) Find(ctx context.Context, filter FindUserFilter, opts FindOp
 func (dao *UserDAO) Get(ctx context.Context, id model.ID) (model.User, error) {
 	logger := dao.Logger.With("query", "get")
 
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return model.User{}, err
+	}
+
 	query, args, err := dao.Builder.
 		Select("*").
 		From("users").
-		Where(squirrel.Eq{"id": id}).
+		Where(squirrel.Eq{"id": id, "organization_id": tenant}).
 		Limit(1).
 		ToSql()
 	if err != nil {
@@ -99,9 +141,8 @@ func (dao *UserDAO) Get(ctx context.Context, id model.ID) (model.User, error) {
 
 	logger.Debug("build query", "sql", query, "args", args)
 
-	var user model.User
-	row := dao.QueryRowxContext(ctx, query, args...)
-	if err := row.StructScan(&user); err != nil {
+	var row userRow
+	if err := dao.QueryRowxContext(ctx, query, args...).StructScan(&row); err != nil {
 		logger.Warn("failed query execute", "error", err)
 
 		if IsNoRows(err) {
@@ -111,49 +152,61 @@ func (dao *UserDAO) Get(ctx context.Context, id model.ID) (model.User, error) {
 		return model.User{}, err
 	}
 
-	logger.Debug("success query execute", "user", user)
+	user, err := dao.decodeUser(row)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	logger.Debug("success query execute", "userId", user.ID)
 
 	return user, nil
 }
 
 type InsertUserDTO struct {
-	Name           string
-	Surname        string
-	Patronymic     *string
-	PassportSerie  int
-	PassportNumber int
-	Address        string
+	Name             string
+	Surname          string
+	Patronymic       *string
+	Passport         model.Passport
+	Address          string
+	EnrichmentStatus model.EnrichmentStatus
 }
 
 func NewInsertUserDTO(
 	name string, surname string,
-	passportSerie int, passportNumber int,
+	passport model.Passport,
 	address string,
 ) InsertUserDTO {
 	return InsertUserDTO{
-		Name:           name,
-		Surname:        surname,
-		Patronymic:     nil,
-		PassportSerie:  passportSerie,
-		PassportNumber: passportNumber,
-		Address:        address,
+		Name:             name,
+		Surname:          surname,
+		Patronymic:       nil,
+		Passport:         passport,
+		Address:          address,
+		EnrichmentStatus: model.EnrichmentCompleted,
+	}
+}
+
+func NewPendingInsertUserDTO(passport model.Passport) InsertUserDTO {
+	return InsertUserDTO{
+		Passport:         passport,
+		EnrichmentStatus: model.EnrichmentPending,
 	}
 }
 
 func NewInsertUserDTOWithPatronymic(
 	name string, surname string, patronymic string,
-	passportSerie int, passportNumber int,
+	passport model.Passport,
 	address string,
 ) InsertUserDTO {
 	copyPatronymic := new(string)
 	*copyPatronymic = patronymic
 	return InsertUserDTO{
-		Name:           name,
-		Surname:        surname,
-		Patronymic:     copyPatronymic,
-		PassportSerie:  passportSerie,
-		PassportNumber: passportNumber,
-		Address:        address,
+		Name:             name,
+		Surname:          surname,
+		Patronymic:       copyPatronymic,
+		Passport:         passport,
+		Address:          address,
+		EnrichmentStatus: model.EnrichmentCompleted,
 	}
 }
 
@@ -165,21 +218,31 @@ func (dto *InsertUserDTO) SetPatronymic(patronymic string) {
 func (dao *UserDAO) Insert(ctx context.Context, dto InsertUserDTO) (model.ID, error) {
 	logger := dao.Logger.With("query", "insert")
 
-	query, args, err := dao.Builder.
-		Insert("users").
-		Columns("name", "surname", "patronymic", "passport_serie", "passport_number", "address").
-		Values(dto.Name, dto.Surname, dto.Patronymic, dto.PassportSerie, dto.PassportNumber, dto.Address).
-		Suffix("RETURNING id").
-		ToSql()
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query, args, err := dao.insertQuery(tenant, dto, "RETURNING *")
 	if err != nil {
 		return 0, err
 	}
 
 	logger.Debug("build query", "sql", query, "args", args)
 
-	var id model.ID
-	row := dao.QueryRowxContext(ctx, query, args...)
-	if err := row.Scan(&id); err != nil {
+	var user model.User
+	if err := dao.withTx(ctx, func(tx *sqlx.Tx) error {
+		var row userRow
+		if err := tx.QueryRowxContext(ctx, query, args...).StructScan(&row); err != nil {
+			return err
+		}
+
+		if user, err = dao.decodeUser(row); err != nil {
+			return err
+		}
+
+		return dao.insertAuditEntry(ctx, tx, logger, AuditEntityUser, user.ID, model.AuditCreate, nil, user.WithMaskedPassport())
+	}); err != nil {
 		logger.Warn("failed query execute", "error", err)
 
 		if IsUniqueViolation(err) {
@@ -189,24 +252,117 @@ func (dao *UserDAO) Insert(ctx context.Context, dto InsertUserDTO) (model.ID, er
 		return 0, err
 	}
 
-	logger.Debug("success query execute", "insertId", id)
+	logger.Debug("success query execute", "insertId", user.ID)
 
-	return id, nil
+	return user.ID, nil
+}
+
+// InsertBatch inserts users in a single transaction. Users with already existing passport are skipped,
+// their IDs in the result are zero.
+func (dao *UserDAO) InsertBatch(ctx context.Context, dtos []InsertUserDTO) ([]model.ID, error) {
+	logger := dao.Logger.With("query", "insertBatch")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return []model.ID{}, err
+	}
+
+	ids := make([]model.ID, len(dtos))
+	if err := dao.withTx(ctx, func(tx *sqlx.Tx) error {
+		for i, dto := range dtos {
+			query, args, err := dao.insertQuery(tenant, dto, "ON CONFLICT DO NOTHING RETURNING *")
+			if err != nil {
+				return err
+			}
+
+			logger.Debug("build query", "sql", query, "args", args)
+
+			var row userRow
+			if err := tx.QueryRowxContext(ctx, query, args...).StructScan(&row); err != nil {
+				if IsNoRows(err) {
+					continue
+				}
+				return err
+			}
+
+			user, err := dao.decodeUser(row)
+			if err != nil {
+				return err
+			}
+			ids[i] = user.ID
+
+			if err := dao.insertAuditEntry(ctx, tx, logger, AuditEntityUser, user.ID, model.AuditCreate, nil, user.WithMaskedPassport()); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		return []model.ID{}, err
+	}
+
+	logger.Debug("success query execute", "countUsers", len(dtos))
+
+	return ids, nil
+}
+
+func (dao *UserDAO) insertQuery(tenant model.ID, dto InsertUserDTO, suffix string) (string, []any, error) {
+	data, err := dao.encodePassport(dto.Passport)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data["organization_id"] = tenant
+	data["name"] = dto.Name
+	data["surname"] = dto.Surname
+	data["patronymic"] = dto.Patronymic
+	data["address"] = dto.Address
+	data["enrichment_status"] = dto.EnrichmentStatus
+
+	return dao.Builder.
+		Insert("users").
+		SetMap(data).
+		Suffix(suffix).
+		ToSql()
 }
 
 type UpdateUserDTO struct {
-	Name           *string
-	Surname        *string
-	Patronymic     *string
-	PassportSerie  *int
-	PassportNumber *int
-	Address        *string
+	Name             *string
+	Surname          *string
+	Patronymic       *string
+	PassportSerie    *string
+	PassportNumber   *string
+	Address          *string
+	EnrichmentStatus *model.EnrichmentStatus
+	Role             *model.Role
+	CanViewPassports *bool
+
+	// ClearPatronymic sets patronymic to NULL, Patronymic is ignored.
+	ClearPatronymic bool
+
+	// IfMatch applies the update only to one of the versions, nil allows any version.
+	IfMatch []int64
 }
 
 func (dao *UserDAO) Update(ctx context.Context, id model.ID, dto UpdateUserDTO) error {
+	_, err := dao.UpdateWithChanges(ctx, id, dto, nil)
+	return err
+}
+
+// UpdateWithChanges updates the user and records the changes in one transaction.
+func (dao *UserDAO) UpdateWithChanges(
+	ctx context.Context, id model.ID, dto UpdateUserDTO, changes []InsertUserChangeDTO,
+) ([]model.UserChange, error) {
 	logger := dao.Logger.With("query", "update")
 
-	data := make(map[string]any, 7)
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return []model.UserChange{}, err
+	}
+
+	data := make(map[string]any, 14)
 	data["updated_at"] = time.Now()
 	if dto.Name != nil {
 		data["name"] = *dto.Name
@@ -214,51 +370,135 @@ func (dao *UserDAO) Update(ctx context.Context, id model.ID, dto UpdateUserDTO)
 	if dto.Surname != nil {
 		data["surname"] = *dto.Surname
 	}
-	if dto.Patronymic != nil {
+	if dto.ClearPatronymic {
+		data["patronymic"] = nil
+	} else if dto.Patronymic != nil {
 		data["patronymic"] = *dto.Patronymic
 	}
-	if dto.PassportSerie != nil {
-		data["passport_serie"] = *dto.PassportSerie
-	}
-	if dto.PassportNumber != nil {
-		data["passport_number"] = *dto.PassportNumber
-	}
 	if dto.Address != nil {
 		data["address"] = *dto.Address
 	}
-
-	query, args, err := dao.Builder.
-		Update("users").
-		SetMap(data).
-		Where(squirrel.Eq{"id": id}).
-		ToSql()
-	if err != nil {
-		return err
+	if dto.EnrichmentStatus != nil {
+		data["enrichment_status"] = *dto.EnrichmentStatus
+	}
+	if dto.Role != nil {
+		data["role"] = *dto.Role
+	}
+	if dto.CanViewPassports != nil {
+		data["can_view_passports"] = *dto.CanViewPassports
 	}
 
-	logger.Debug("build query", "sql", query, "args", args)
+	var recorded []model.UserChange
+	if err := dao.withTx(ctx, func(tx *sqlx.Tx) error {
+		before, err := dao.getForUpdate(ctx, tx, tenant, id)
+		if err != nil {
+			return err
+		}
+		if before.ErasedAt != nil {
+			return model.NewError("user", model.ErrErased)
+		}
+		if dto.IfMatch != nil && !slices.Contains(dto.IfMatch, before.Version) {
+			return model.NewError("user", model.ErrVersionMismatch)
+		}
+
+		// Both passport parts are encrypted together, so a partial change is merged with the stored value.
+		if dto.PassportSerie != nil || dto.PassportNumber != nil {
+			passport := before.Passport()
+			if dto.PassportSerie != nil {
+				passport.Serie = *dto.PassportSerie
+			}
+			if dto.PassportNumber != nil {
+				passport.Number = *dto.PassportNumber
+			}
+
+			passportData, err := dao.encodePassport(passport)
+			if err != nil {
+				return err
+			}
+			for column, value := range passportData {
+				data[column] = value
+			}
+		}
+
+		query, args, err := dao.Builder.
+			Update("users").
+			SetMap(data).
+			Where(squirrel.Eq{"id": id, "organization_id": tenant}).
+			Suffix("RETURNING *").
+			ToSql()
+		if err != nil {
+			return err
+		}
+
+		logger.Debug("build query", "sql", query, "args", args)
+
+		var row userRow
+		if err := tx.QueryRowxContext(ctx, query, args...).StructScan(&row); err != nil {
+			return err
+		}
+
+		after, err := dao.decodeUser(row)
+		if err != nil {
+			return err
+		}
+
+		if err := dao.insertAuditEntry(ctx, tx, logger, AuditEntityUser, id, model.AuditUpdate, before.WithMaskedPassport(), after.WithMaskedPassport()); err != nil {
+			return err
+		}
 
-	if _, err = dao.ExecContext(ctx, query, args...); err != nil {
+		recorded, err = dao.insertUserChanges(ctx, tx, logger, id, changes)
+		return err
+	}); err != nil {
 		logger.Warn("failed query execute", "error", err)
 
+		if IsNoRows(err) {
+			return []model.UserChange{}, model.NewError("user", model.ErrNotFound)
+		}
 		if IsUniqueViolation(err) {
-			return model.NewError("user", model.ErrExists)
+			return []model.UserChange{}, model.NewError("user", model.ErrExists)
 		}
 
-		return err
+		return []model.UserChange{}, err
 	}
 
-	logger.Debug("success query execute", "updateId", id, "countUpdatedFields", len(data))
+	logger.Debug("success query execute", "updateId", id, "countUpdatedFields", len(data), "countChanges", len(recorded))
 
-	return nil
+	return recorded, nil
 }
 
-func (dao *UserDAO) Delete(ctx context.Context, id model.ID) error {
+// getForUpdate locks the user row until the end of the transaction.
+func (dao *UserDAO) getForUpdate(ctx context.Context, tx *sqlx.Tx, tenant model.ID, id model.ID) (model.User, error) {
+	query, args, err := dao.Builder.
+		Select("*").
+		From("users").
+		Where(squirrel.Eq{"id": id, "organization_id": tenant}).
+		Suffix("FOR UPDATE").
+		ToSql()
+	if err != nil {
+		return model.User{}, err
+	}
+
+	var row userRow
+	if err := tx.QueryRowxContext(ctx, query, args...).StructScan(&row); err != nil {
+		return model.User{}, err
+	}
+
+	return dao.decodeUser(row)
+}
+
+// Delete deletes the user, ifMatch deletes only one of the versions, nil allows any version.
+func (dao *UserDAO) Delete(ctx context.Context, id model.ID, ifMatch []int64) error {
 	logger := dao.Logger.With("query", "delete")
 
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
 	query, args, err := dao.Builder.
 		Delete("users").
-		Where(squirrel.Eq{"id": id}).
+		Where(squirrel.Eq{"id": id, "organization_id": tenant}).
+		Suffix("RETURNING *").
 		ToSql()
 	if err != nil {
 		return err
@@ -266,7 +506,39 @@ func (dao *UserDAO) Delete(ctx context.Context, id model.ID) error {
 
 	logger.Debug("build query", "sql", query, "args", args)
 
-	if _, err = dao.ExecContext(ctx, query, args...); err != nil {
+	if err := dao.withTx(ctx, func(tx *sqlx.Tx) error {
+		if ifMatch != nil {
+			current, err := dao.getForUpdate(ctx, tx, tenant, id)
+			if err != nil {
+				if IsNoRows(err) {
+					return nil
+				}
+				return err
+			}
+			if !slices.Contains(ifMatch, current.Version) {
+				return model.NewError("user", model.ErrVersionMismatch)
+			}
+		}
+
+		var row userRow
+		if err := tx.QueryRowxContext(ctx, query, args...).StructScan(&row); err != nil {
+			if IsNoRows(err) {
+				return nil
+			}
+			return err
+		}
+
+		before, err := dao.decodeUser(row)
+		if err != nil {
+			return err
+		}
+
+		if err := execQuery(ctx, tx, logger, deleteIdempotentResponses(dao.Builder, tenant, id)); err != nil {
+			return err
+		}
+
+		return dao.insertAuditEntry(ctx, tx, logger, AuditEntityUser, id, model.AuditDelete, before.WithMaskedPassport(), nil)
+	}); err != nil {
 		logger.Warn("failed query execute", "error", err)
 
 		return err
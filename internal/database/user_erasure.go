@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+// _personalAuditFields are JSON fields of model.User removed from the audit log on erasure.
+var _personalAuditFields = []string{"name", "surname", "patronymic", "passportSerie", "passwortNumber", "address"}
+
+// Erase anonymises personal data of the user on a subject request: personal fields and passport
+// are cleared, open sessions are stopped, login sessions, API keys, team memberships and
+// change history are deleted, stored idempotent responses referring to the user are deleted
+// and personal data is redacted from the audit log.
+// Sessions are kept, they refer only to the de-identified user.
+func (dao *UserDAO) Erase(ctx context.Context, id model.ID) (time.Time, error) {
+	logger := dao.Logger.With("query", "erase")
+
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	erasedAt := time.Now()
+
+	if err := dao.withTx(ctx, func(tx *sqlx.Tx) error {
+		user, err := dao.getForUpdate(ctx, tx, tenant, id)
+		if err != nil {
+			return err
+		}
+		if user.ErasedAt != nil {
+			return model.NewError("user", model.ErrErased)
+		}
+
+		queries := make([]squirrel.Sqlizer, 0, 8)
+
+		queries = append(queries, dao.Builder.
+			Update("users").
+			SetMap(map[string]any{
+				"name":                 "",
+				"surname":              "",
+				"patronymic":           nil,
+				"address":              "",
+				"passport_serie":       nil,
+				"passport_number":      nil,
+				"passport_key_id":      nil,
+				"passport_serie_enc":   nil,
+				"passport_number_enc":  nil,
+				"passport_serie_hash":  nil,
+				"passport_number_hash": nil,
+				"role":                 model.RoleEmployee,
+				"can_view_passports":   false,
+				"erased_at":            erasedAt,
+				"updated_at":           erasedAt,
+			}).
+			Where(squirrel.Eq{"id": id, "organization_id": tenant}))
+
+		queries = append(queries, dao.Builder.
+			Update("sessions").
+			Set("sess_end", erasedAt).
+			Where(squirrel.Eq{"user_id": id, "organization_id": tenant, "sess_end": nil}))
+
+		for _, table := range []string{"user_changes", "login_codes", "auth_sessions", "api_keys", "team_members"} {
+			queries = append(queries, dao.Builder.
+				Delete(table).
+				Where(squirrel.Eq{"user_id": id}))
+		}
+
+		queries = append(queries, deleteIdempotentResponses(dao.Builder, tenant, id))
+
+		for _, query := range queries {
+			if err := execQuery(ctx, tx, logger, query); err != nil {
+				return err
+			}
+		}
+
+		if err := dao.redactAuditLog(ctx, tx, logger, tenant, id); err != nil {
+			return err
+		}
+
+		erasure := struct {
+			ErasedAt time.Time `json:"erasedAt"`
+		}{ErasedAt: erasedAt}
+
+		return dao.insertAuditEntry(ctx, tx, logger, AuditEntityUser, id, model.AuditUpdate, nil, erasure)
+	}); err != nil {
+		logger.Warn("failed query execute", "error", err)
+
+		if IsNoRows(err) {
+			return time.Time{}, model.NewError("user", model.ErrNotFound)
+		}
+
+		return time.Time{}, err
+	}
+
+	logger.Debug("success query execute", "erasedId", id)
+
+	return erasedAt, nil
+}
+
+// redactAuditLog removes personal fields from audited states of the user.
+func (dao *UserDAO) redactAuditLog(ctx context.Context, tx *sqlx.Tx, logger *slog.Logger, tenant, id model.ID) error {
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('audit_log.redaction', 'on', true)"); err != nil {
+		return err
+	}
+
+	query := dao.Builder.
+		Update("audit_log").
+		Set("before", squirrel.Expr("before - ?::text[]", _personalAuditFields)).
+		Set("after", squirrel.Expr("after - ?::text[]", _personalAuditFields)).
+		Where(squirrel.Eq{"organization_id": tenant, "entity": AuditEntityUser, "entity_id": id})
+	if err := execQuery(ctx, tx, logger, query); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, "SELECT set_config('audit_log.redaction', 'off', true)")
+	return err
+}
+
+func execQuery(ctx context.Context, tx *sqlx.Tx, logger *slog.Logger, stmt squirrel.Sqlizer) error {
+	query, args, err := stmt.ToSql()
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	return err
+}
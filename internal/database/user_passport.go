@@ -0,0 +1,197 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+const (
+	_passportSerieIndex  = "passport_serie"
+	_passportNumberIndex = "passport_number"
+)
+
+var ErrPIIRequired = errors.New("personal data protector is not configured")
+
+// userRow is the stored user. Passport is encrypted, rows created before encryption
+// keep it in plain text until ReencryptPassports.
+type userRow struct {
+	model.User
+
+	PlainPassportSerie  *string `db:"passport_serie"`
+	PlainPassportNumber *string `db:"passport_number"`
+
+	PassportKeyID      *uint   `db:"passport_key_id"`
+	PassportSerieEnc   *string `db:"passport_serie_enc"`
+	PassportNumberEnc  *string `db:"passport_number_enc"`
+	PassportSerieHash  *string `db:"passport_serie_hash"`
+	PassportNumberHash *string `db:"passport_number_hash"`
+}
+
+func (dao *UserDAO) decodeUser(row userRow) (model.User, error) {
+	user := row.User
+
+	if row.PassportKeyID == nil || row.PassportSerieEnc == nil || row.PassportNumberEnc == nil {
+		if row.PlainPassportSerie != nil && row.PlainPassportNumber != nil {
+			user.PassportSerie, user.PassportNumber = *row.PlainPassportSerie, *row.PlainPassportNumber
+		}
+		return user, nil
+	}
+
+	if dao.PII == nil {
+		return model.User{}, ErrPIIRequired
+	}
+
+	var err error
+	if user.PassportSerie, err = dao.PII.Decrypt(*row.PassportKeyID, *row.PassportSerieEnc); err != nil {
+		return model.User{}, err
+	}
+	if user.PassportNumber, err = dao.PII.Decrypt(*row.PassportKeyID, *row.PassportNumberEnc); err != nil {
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+func (dao *UserDAO) decodeUsers(rows []userRow) ([]model.User, error) {
+	users := make([]model.User, 0, len(rows))
+	for _, row := range rows {
+		user, err := dao.decodeUser(row)
+		if err != nil {
+			return []model.User{}, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// encodePassport returns columns of the encrypted passport, plain text columns are cleared.
+func (dao *UserDAO) encodePassport(passport model.Passport) (map[string]any, error) {
+	if dao.PII == nil {
+		return nil, ErrPIIRequired
+	}
+
+	serie, err := dao.PII.Encrypt(passport.Serie)
+	if err != nil {
+		return nil, err
+	}
+
+	number, err := dao.PII.Encrypt(passport.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"passport_serie":       nil,
+		"passport_number":      nil,
+		"passport_key_id":      dao.PII.PrimaryKey(),
+		"passport_serie_enc":   serie,
+		"passport_number_enc":  number,
+		"passport_serie_hash":  dao.PII.Index(_passportSerieIndex, passport.Serie),
+		"passport_number_hash": dao.PII.Index(_passportNumberIndex, passport.Number),
+	}, nil
+}
+
+// passportIndexFilter matches users by blind indexes of passport parts.
+func (dao *UserDAO) passportIndexFilter(serie, number *string) (squirrel.Eq, error) {
+	equals := squirrel.Eq{}
+	if serie == nil && number == nil {
+		return equals, nil
+	}
+
+	if dao.PII == nil {
+		return nil, ErrPIIRequired
+	}
+
+	if serie != nil {
+		equals["passport_serie_hash"] = dao.PII.Index(_passportSerieIndex, *serie)
+	}
+	if number != nil {
+		equals["passport_number_hash"] = dao.PII.Index(_passportNumberIndex, *number)
+	}
+
+	return equals, nil
+}
+
+// ReencryptPassports encrypts plain text passports and passports encrypted with
+// a non-primary key in batches. It is not tenant scoped and is run on startup.
+func (dao *UserDAO) ReencryptPassports(ctx context.Context, batchSize uint64) (int, error) {
+	logger := dao.Logger.With("query", "reencryptPassports")
+
+	if dao.PII == nil {
+		return 0, ErrPIIRequired
+	}
+
+	query, args, err := dao.Builder.
+		Select("*").
+		From("users").
+		Where(squirrel.Eq{"erased_at": nil}).
+		Where(squirrel.Or{
+			squirrel.Eq{"passport_key_id": nil},
+			squirrel.NotEq{"passport_key_id": dao.PII.PrimaryKey()},
+		}).
+		OrderBy("id ASC").
+		Limit(batchSize).
+		Suffix("FOR UPDATE SKIP LOCKED").
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	logger.Debug("build query", "sql", query, "args", args)
+
+	total := 0
+	for {
+		count := 0
+		if err := dao.withTx(ctx, func(tx *sqlx.Tx) error {
+			var rows []userRow
+			if err := tx.SelectContext(ctx, &rows, query, args...); err != nil {
+				return err
+			}
+			count = len(rows)
+
+			for _, row := range rows {
+				user, err := dao.decodeUser(row)
+				if err != nil {
+					return err
+				}
+
+				data, err := dao.encodePassport(user.Passport())
+				if err != nil {
+					return err
+				}
+
+				updateQuery, updateArgs, err := dao.Builder.
+					Update("users").
+					SetMap(data).
+					Where(squirrel.Eq{"id": user.ID}).
+					ToSql()
+				if err != nil {
+					return err
+				}
+
+				if _, err := tx.ExecContext(ctx, updateQuery, updateArgs...); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}); err != nil {
+			logger.Warn("failed query execute", "error", err)
+
+			return total, err
+		}
+
+		total += count
+		if uint64(count) < batchSize {
+			break
+		}
+	}
+
+	logger.Debug("success query execute", "countUsers", total)
+
+	return total, nil
+}
@@ -0,0 +1,77 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/protomem/time-tracker/internal/metrics"
+	"github.com/protomem/time-tracker/internal/model"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrLookupUnsupported = errors.New("lookup is not supported by identity provider")
+	ErrInvalidPerson     = errors.New("identity provider returned incomplete person data")
+)
+
+const (
+	ProviderPeopleService = "people_service"
+	ProviderManual        = "manual"
+)
+
+type Document struct {
+	Passport model.Passport
+}
+
+type Person struct {
+	Name       string
+	Surname    string
+	Patronymic *string
+	Address    string
+}
+
+// Validate reports ErrInvalidPerson if data required for an enriched user is blank.
+func (p Person) Validate() error {
+	blank := func(s string) bool { return strings.TrimSpace(s) == "" }
+
+	if blank(p.Name) || blank(p.Surname) || blank(p.Address) || (p.Patronymic != nil && blank(*p.Patronymic)) {
+		return ErrInvalidPerson
+	}
+
+	return nil
+}
+
+// Provider looks up person data by identity document.
+type Provider interface {
+	// Lookup returns person data or model.ErrNotFound if there is no person with the document.
+	Lookup(ctx context.Context, doc Document) (Person, error)
+
+	// Manual reports whether person data must be provided by the client instead of being looked up.
+	Manual() bool
+}
+
+// Checker is implemented by providers depending on external services.
+type Checker interface {
+	// Check reports an error if the external service is not reachable.
+	Check(ctx context.Context) error
+}
+
+func New(
+	logger *slog.Logger, name string, peopleServiceURL string, peopleServiceTimeout time.Duration,
+	m *metrics.Metrics, tracerProvider trace.TracerProvider,
+) (Provider, error) {
+	logger = logger.With("module", "identity", "provider", name)
+
+	switch name {
+	case ProviderPeopleService:
+		return NewPeopleServiceProvider(logger, peopleServiceURL, peopleServiceTimeout, m, tracerProvider)
+	case ProviderManual:
+		return NewManualProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown identity provider %q", name)
+	}
+}
@@ -0,0 +1,21 @@
+package identity
+
+import "context"
+
+var _ Provider = (*ManualProvider)(nil)
+
+// ManualProvider is used when there is no external identity service,
+// person data is accepted from the client as is.
+type ManualProvider struct{}
+
+func NewManualProvider() *ManualProvider {
+	return &ManualProvider{}
+}
+
+func (*ManualProvider) Lookup(context.Context, Document) (Person, error) {
+	return Person{}, ErrLookupUnsupported
+}
+
+func (*ManualProvider) Manual() bool {
+	return true
+}
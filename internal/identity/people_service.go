@@ -0,0 +1,149 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/protomem/time-tracker/internal/external_api/people_service"
+	"github.com/protomem/time-tracker/internal/metrics"
+	"github.com/protomem/time-tracker/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	_ Provider = (*PeopleServiceProvider)(nil)
+	_ Checker  = (*PeopleServiceProvider)(nil)
+)
+
+type PeopleServiceProvider struct {
+	Logger  *slog.Logger
+	Metrics *metrics.Metrics
+	addr    string
+	http    propagatingClient
+	client  *people_service.Client
+}
+
+func NewPeopleServiceProvider(
+	logger *slog.Logger, addr string, timeout time.Duration,
+	m *metrics.Metrics, tracerProvider trace.TracerProvider,
+) (*PeopleServiceProvider, error) {
+	logger.Debug("connect to people service", "addr", addr)
+
+	httpClient := propagatingClient{client: &http.Client{Timeout: timeout}}
+
+	client, err := people_service.NewClient(addr,
+		people_service.WithTracerProvider(tracerProvider),
+		people_service.WithClient(httpClient),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PeopleServiceProvider{
+		Logger:  logger,
+		Metrics: m,
+		addr:    addr,
+		http:    httpClient,
+		client:  client,
+	}, nil
+}
+
+func (p *PeopleServiceProvider) Lookup(ctx context.Context, doc Document) (Person, error) {
+	start := time.Now()
+
+	person, err := p.lookup(ctx, doc)
+
+	outcome := metrics.OutcomeSuccess
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+		outcome = metrics.OutcomeNotFound
+	case err != nil:
+		outcome = metrics.OutcomeError
+	}
+	p.Metrics.ObservePeopleServiceRequest(outcome, time.Since(start))
+
+	return person, err
+}
+
+func (p *PeopleServiceProvider) lookup(ctx context.Context, doc Document) (Person, error) {
+	p.Logger.Debug("do people request", "passport", doc.Passport)
+
+	// People service accepts passport as numbers, digit strings are always convertible.
+	passportSerie, err := strconv.Atoi(doc.Passport.Serie)
+	if err != nil {
+		return Person{}, err
+	}
+	passportNumber, err := strconv.Atoi(doc.Passport.Number)
+	if err != nil {
+		return Person{}, err
+	}
+
+	infoPeopleReq, err := p.client.InfoGet(ctx, people_service.InfoGetParams{
+		PassportSerie:  passportSerie,
+		PassportNumber: passportNumber,
+	})
+	if err != nil {
+		return Person{}, err
+	}
+
+	switch infoPeopleReq := infoPeopleReq.(type) {
+	case (*people_service.People):
+		p.Logger.Debug("fetch people", "people", infoPeopleReq)
+
+		person := Person{
+			Name:    infoPeopleReq.GetName(),
+			Surname: infoPeopleReq.GetSurname(),
+			Address: infoPeopleReq.GetAddress(),
+		}
+		if infoPeopleReq.GetPatronymic().Set {
+			patronymic := infoPeopleReq.GetPatronymic().Value
+			person.Patronymic = &patronymic
+		}
+
+		return person, nil
+	default:
+		p.Logger.Warn("unknown people response", "people", fmt.Sprintf("%T", infoPeopleReq))
+		return Person{}, model.NewError("user", model.ErrNotFound)
+	}
+}
+
+func (p *PeopleServiceProvider) Manual() bool {
+	return false
+}
+
+// Check requests the service root, any response except server errors means the service is up.
+func (p *PeopleServiceProvider) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.addr, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("people service responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// propagatingClient passes the trace context of the request to the people service.
+type propagatingClient struct {
+	client *http.Client
+}
+
+func (c propagatingClient) Do(req *http.Request) (*http.Response, error) {
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	return c.client.Do(req)
+}
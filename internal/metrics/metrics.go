@@ -0,0 +1,154 @@
+// Package metrics exposes application metrics in the Prometheus format.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/protomem/time-tracker/internal/model"
+)
+
+const (
+	_namespace      = "time_tracker"
+	_collectTimeout = 2 * time.Second
+)
+
+// Outcomes of people service calls.
+const (
+	OutcomeSuccess  = "success"
+	OutcomeNotFound = "not_found"
+	OutcomeError    = "error"
+)
+
+type Metrics struct {
+	Logger   *slog.Logger
+	registry *prometheus.Registry
+
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+
+	peopleServiceRequests        *prometheus.CounterVec
+	peopleServiceRequestDuration *prometheus.HistogramVec
+}
+
+func New(logger *slog.Logger) *Metrics {
+	m := &Metrics{
+		Logger:   logger.With("module", "metrics"),
+		registry: prometheus.NewRegistry(),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: _namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by route pattern and status.",
+		}, []string{"method", "route", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: _namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by route pattern and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+
+		peopleServiceRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: _namespace,
+			Name:      "people_service_requests_total",
+			Help:      "Number of people service requests by outcome.",
+		}, []string{"outcome"}),
+		peopleServiceRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: _namespace,
+			Name:      "people_service_request_duration_seconds",
+			Help:      "Latency of people service requests by outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"outcome"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.peopleServiceRequests,
+		m.peopleServiceRequestDuration,
+	)
+
+	return m
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		ErrorLog: slog.NewLogLogger(m.Logger.Handler(), slog.LevelWarn),
+	})
+}
+
+// ObserveHTTPRequest records the request, route is the chi route pattern.
+func (m *Metrics) ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	labels := prometheus.Labels{"method": method, "route": route, "status": strconv.Itoa(status)}
+	m.httpRequests.With(labels).Inc()
+	m.httpRequestDuration.With(labels).Observe(duration.Seconds())
+}
+
+// ObservePeopleServiceRequest records the people service call, outcome is one of Outcome* constants.
+func (m *Metrics) ObservePeopleServiceRequest(outcome string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.peopleServiceRequests.WithLabelValues(outcome).Inc()
+	m.peopleServiceRequestDuration.WithLabelValues(outcome).Observe(duration.Seconds())
+}
+
+// RegisterDB exposes connection pool stats of the database.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterOpenSessions exposes number of not ended sessions per organization,
+// count is called on every scrape.
+func (m *Metrics) RegisterOpenSessions(count func(ctx context.Context) (map[model.ID]int, error)) {
+	m.registry.MustRegister(&countCollector{
+		logger: m.Logger,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(_namespace, "", "open_sessions"),
+			"Number of not ended sessions per organization.",
+			[]string{"organization"}, nil,
+		),
+		count: count,
+	})
+}
+
+// countCollector reports gauges counted by a query at scrape time.
+type countCollector struct {
+	logger *slog.Logger
+	desc   *prometheus.Desc
+	count  func(ctx context.Context) (map[model.ID]int, error)
+}
+
+func (c *countCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *countCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), _collectTimeout)
+	defer cancel()
+
+	counts, err := c.count(ctx)
+	if err != nil {
+		c.logger.Warn("failed to collect metric", "metric", c.desc.String(), "error", err)
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	for id, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count), strconv.FormatUint(uint64(id), 10))
+	}
+}
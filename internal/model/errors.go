@@ -6,13 +6,61 @@ import (
 	"strings"
 )
 
+// Kinds of domain errors, check them with errors.Is.
 var (
 	ErrNotFound = errors.New("not found")
 	ErrExists   = errors.New("already exists")
+	ErrErased   = errors.New("erased")
+	// ErrVersionMismatch is returned by conditional updates when the entity was changed by someone else.
+	ErrVersionMismatch = errors.New("version mismatch")
 )
 
-func NewError(model string, err error) error {
-	return fmt.Errorf("%s %w", capitalize(model), err)
+// Codes of domain errors which differ from the default <entity>_<kind> form.
+const (
+	CodeSessionAlreadyRunning = "session_already_running"
+)
+
+// Error is an error of a domain entity. Code is stable and machine-readable,
+// e.g. user_not_found, so clients do not depend on the message.
+type Error struct {
+	Entity string
+	Kind   error
+	Code   string
+}
+
+func NewError(entity string, kind error) error {
+	return &Error{Entity: entity, Kind: kind, Code: errorCode(entity, kind)}
+}
+
+// NewErrorWithCode is NewError with a code more specific than the default one.
+func NewErrorWithCode(entity string, kind error, code string) error {
+	return &Error{Entity: entity, Kind: kind, Code: code}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s", capitalize(e.Entity), e.Kind)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func errorCode(entity string, kind error) string {
+	var suffix string
+	switch {
+	case errors.Is(kind, ErrNotFound):
+		suffix = "not_found"
+	case errors.Is(kind, ErrExists):
+		suffix = "already_exists"
+	case errors.Is(kind, ErrErased):
+		suffix = "erased"
+	case errors.Is(kind, ErrVersionMismatch):
+		suffix = "version_mismatch"
+	default:
+		suffix = "error"
+	}
+
+	return strings.ReplaceAll(strings.ToLower(entity), " ", "_") + "_" + suffix
 }
 
 func capitalize(s string) string {
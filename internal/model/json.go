@@ -0,0 +1,42 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// RawJSON is a JSON document stored as is, e.g. in a JSONB column. Empty value is null.
+type RawJSON []byte
+
+func (j RawJSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+func (j *RawJSON) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[:0], data...)
+	return nil
+}
+
+func (j *RawJSON) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append((*j)[:0], src...)
+	case string:
+		*j = RawJSON(src)
+	default:
+		return fmt.Errorf("model: cannot scan %T into RawJSON", src)
+	}
+	return nil
+}
+
+func (j RawJSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
@@ -4,29 +4,264 @@ import "time"
 
 type ID = uint
 
+// DefaultOrganization owns the data created before organizations were introduced.
+const DefaultOrganization ID = 1
+
+type Organization struct {
+	ID        ID        `json:"id" db:"id"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+
+	Name string `json:"name" db:"name"`
+}
+
 type User struct {
 	ID        ID        `json:"id" db:"id"`
 	CreatedAt time.Time `json:"createdAt" db:"created_at"`
 	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+	// Version is incremented on every update, see ETag.
+	Version int64 `json:"-" db:"version"`
 
 	Name       string  `json:"name" db:"name"`
 	Surname    string  `json:"surname" db:"surname"`
 	Patronymic *string `json:"patronymic,omitempty" db:"patronymic"`
 
-	PassportSerie  int `json:"passportSerie" db:"passport_serie"`
-	PassportNumber int `json:"passwortNumber" db:"passport_number"`
+	// Passport is stored encrypted, see database.UserDAO.
+	PassportSerie  string `json:"passportSerie" db:"-"`
+	PassportNumber string `json:"passwortNumber" db:"-"`
 
 	Address string `json:"address" db:"address"`
+
+	EnrichmentStatus EnrichmentStatus `json:"enrichmentStatus" db:"enrichment_status"`
+
+	Role Role `json:"role" db:"role"`
+	// CanViewPassports allows to see unmasked passports of other users, it is not implied by role.
+	CanViewPassports bool `json:"canViewPassports" db:"can_view_passports"`
+
+	Organization ID `json:"organizationId" db:"organization_id"`
+
+	// ErasedAt is set when personal data of the user is erased on request,
+	// the user is kept to preserve de-identified sessions.
+	ErasedAt *time.Time `json:"erasedAt,omitempty" db:"erased_at"`
+}
+
+func (u User) Passport() Passport {
+	return Passport{Serie: u.PassportSerie, Number: u.PassportNumber}
+}
+
+// WithMaskedPassport hides passport digits except the last two of each part.
+func (u User) WithMaskedPassport() User {
+	u.PassportSerie = MaskPassportPart(u.PassportSerie)
+	u.PassportNumber = MaskPassportPart(u.PassportNumber)
+	return u
+}
+
+type EnrichmentStatus string
+
+const (
+	EnrichmentPending   EnrichmentStatus = "pending"
+	EnrichmentCompleted EnrichmentStatus = "completed"
+	EnrichmentFailed    EnrichmentStatus = "failed"
+)
+
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleManager  Role = "manager"
+	RoleEmployee Role = "employee"
+)
+
+var Roles = []Role{RoleAdmin, RoleManager, RoleEmployee}
+
+type UserChange struct {
+	ID        ID        `json:"id" db:"id"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+
+	User     ID      `json:"userId" db:"user_id"`
+	Field    string  `json:"field" db:"field"`
+	OldValue *string `json:"oldValue" db:"old_value"`
+	NewValue *string `json:"newValue" db:"new_value"`
+}
+
+type Team struct {
+	ID        ID        `json:"id" db:"id"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+
+	Name string `json:"name" db:"name"`
+
+	Organization ID `json:"organizationId" db:"organization_id"`
+}
+
+type TeamRole string
+
+const (
+	TeamRoleMember  TeamRole = "member"
+	TeamRoleManager TeamRole = "manager"
+)
+
+var TeamRoles = []TeamRole{TeamRoleMember, TeamRoleManager}
+
+type TeamMember struct {
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+
+	Team ID       `json:"teamId" db:"team_id"`
+	User ID       `json:"userId" db:"user_id"`
+	Role TeamRole `json:"role" db:"role"`
 }
 
 type Session struct {
 	ID        ID        `json:"id" db:"id"`
 	CreatedAt time.Time `json:"createdAt" db:"created_at"`
 	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+	// Version is incremented on every update, see ETag.
+	Version int64 `json:"-" db:"version"`
 
 	Begin time.Time  `json:"begin" db:"sess_begin"`
 	End   *time.Time `json:"end" db:"sess_end"`
 
 	Task ID `json:"taskId" db:"task_id"`
 	User ID `json:"userId" db:"user_id"`
+
+	Organization ID `json:"organizationId" db:"organization_id"`
+}
+
+// SessionSummary is the monthly total of archived sessions of the user on the task.
+type SessionSummary struct {
+	ID        ID        `json:"id" db:"id"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+
+	User ID `json:"userId" db:"user_id"`
+	Task ID `json:"taskId" db:"task_id"`
+
+	// Month is the first day of the month in UTC.
+	Month time.Time `json:"month" db:"month"`
+
+	SessionsCount int   `json:"sessionsCount" db:"sessions_count"`
+	DurationMs    int64 `json:"durationMs" db:"duration_ms"`
+
+	Organization ID `json:"organizationId" db:"organization_id"`
+}
+
+func (s SessionSummary) Duration() time.Duration {
+	return time.Duration(s.DurationMs) * time.Millisecond
+}
+
+// MonthStart truncates the time to the first day of its month in UTC.
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+type APIKey struct {
+	ID        ID        `json:"id" db:"id"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+
+	Name      string     `json:"name" db:"name"`
+	Prefix    string     `json:"prefix" db:"prefix"`
+	KeyHash   string     `json:"-" db:"key_hash"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty" db:"revoked_at"`
+
+	User *ID `json:"userId,omitempty" db:"user_id"`
+	// CanViewPassports allows the key to see unmasked passports,
+	// key bound to a user has it only when the user has it too.
+	CanViewPassports bool `json:"canViewPassports" db:"can_view_passports"`
+
+	Organization ID `json:"organizationId" db:"organization_id"`
+}
+
+type LoginCode struct {
+	ID        ID        `json:"id" db:"id"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+
+	User      ID         `json:"userId" db:"user_id"`
+	CodeHash  string     `json:"-" db:"code_hash"`
+	ExpiresAt time.Time  `json:"expiresAt" db:"expires_at"`
+	UsedAt    *time.Time `json:"usedAt,omitempty" db:"used_at"`
+
+	Organization ID `json:"organizationId" db:"organization_id"`
+}
+
+// IdempotencyKey keeps the response of a POST request, it is replayed for retries of the same request.
+type IdempotencyKey struct {
+	CreatedAt time.Time `db:"created_at"`
+
+	Scope       string `db:"scope"`
+	Key         string `db:"key"`
+	RequestHash string `db:"request_hash"`
+
+	StatusCode      *int    `db:"status_code"`
+	ResponseHeaders RawJSON `db:"response_headers"`
+	// ResponseBody is decrypted by database.IdempotencyKeyDAO, ResponseKeyID is the ID of the encryption key.
+	ResponseBody  []byte `db:"response_body"`
+	ResponseKeyID *uint  `db:"response_key_id"`
+
+	ExpiresAt time.Time `db:"expires_at"`
+
+	Organization ID `db:"organization_id"`
+}
+
+// Completed reports whether the response of the first request is stored.
+func (k IdempotencyKey) Completed() bool {
+	return k.StatusCode != nil
+}
+
+// AuthSession is a login session of a user, refresh tokens are rotated within it.
+type AuthSession struct {
+	ID        ID        `json:"id" db:"id"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+
+	User      ID         `json:"userId" db:"user_id"`
+	ExpiresAt time.Time  `json:"expiresAt" db:"expires_at"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty" db:"revoked_at"`
+
+	Organization ID `json:"organizationId" db:"organization_id"`
+}
+
+func (s AuthSession) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+type RefreshToken struct {
+	ID        ID        `json:"id" db:"id"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+
+	Session   ID         `json:"sessionId" db:"session_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	UsedAt    *time.Time `json:"usedAt,omitempty" db:"used_at"`
+}
+
+type AuditAction string
+
+const (
+	AuditCreate AuditAction = "create"
+	AuditUpdate AuditAction = "update"
+	AuditDelete AuditAction = "delete"
+)
+
+var AuditActions = []AuditAction{AuditCreate, AuditUpdate, AuditDelete}
+
+// AuditEntry records a change of an entity. Actor is empty for changes made by background jobs.
+// For updates Before and After contain only changed fields.
+type AuditEntry struct {
+	ID        ID        `json:"id" db:"id"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+
+	ActorAPIKey *ID `json:"actorApiKeyId,omitempty" db:"actor_api_key_id"`
+	ActorUser   *ID `json:"actorUserId,omitempty" db:"actor_user_id"`
+
+	Action   AuditAction `json:"action" db:"action"`
+	Entity   string      `json:"entity" db:"entity"`
+	EntityID ID          `json:"entityId" db:"entity_id"`
+	Before   RawJSON     `json:"before" db:"before" swaggertype:"object"`
+	After    RawJSON     `json:"after" db:"after" swaggertype:"object"`
+
+	TraceID *string `json:"traceId,omitempty" db:"trace_id"`
+
+	Organization ID `json:"organizationId" db:"organization_id"`
 }
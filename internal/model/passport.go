@@ -0,0 +1,80 @@
+package model
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"unicode"
+)
+
+const (
+	PassportSerieDigits  = 4
+	PassportNumberDigits = 6
+)
+
+var ErrInvalidPassport = errors.New("invalid passport data: expected 4-digit serie and 6-digit number")
+
+// Passport keeps serie and number as digit strings to preserve leading zeros.
+type Passport struct {
+	Serie  string `json:"serie"`
+	Number string `json:"number"`
+}
+
+// ParsePassport accepts common input forms such as "1234 567890", "12 34 567890",
+// "1234-567890", "1234 № 567890" and "1234567890".
+func ParsePassport(s string) (Passport, error) {
+	digits := NormalizePassportPart(s)
+	if len(digits) != PassportSerieDigits+PassportNumberDigits || !isDigits(digits) {
+		return Passport{}, ErrInvalidPassport
+	}
+
+	return Passport{
+		Serie:  digits[:PassportSerieDigits],
+		Number: digits[PassportSerieDigits:],
+	}, nil
+}
+
+// NormalizePassportPart removes separators from passport serie, number or whole passport.
+func NormalizePassportPart(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '-' || r == '№' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// MaskPassportPart keeps only the last two characters, e.g. ****56.
+func MaskPassportPart(s string) string {
+	const visible = 2
+	if len(s) <= visible {
+		return strings.Repeat("*", len(s))
+	}
+	return strings.Repeat("*", len(s)-visible) + s[len(s)-visible:]
+}
+
+func (p Passport) String() string {
+	return p.Serie + " " + p.Number
+}
+
+// Masked returns the passport with masked serie and number.
+func (p Passport) Masked() Passport {
+	return Passport{
+		Serie:  MaskPassportPart(p.Serie),
+		Number: MaskPassportPart(p.Number),
+	}
+}
+
+// LogValue keeps plaintext passport out of the logs.
+func (p Passport) LogValue() slog.Value {
+	return slog.StringValue(p.Masked().String())
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,164 @@
+// Package pii protects personal data at rest: values are encrypted with AES-GCM
+// and searched by a blind index (HMAC-SHA256).
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	KeySize      = 32
+	MinIndexSize = 32
+)
+
+var (
+	ErrUnknownKey    = errors.New("pii: unknown encryption key")
+	ErrMalformedData = errors.New("pii: malformed encrypted data")
+)
+
+// Protector encrypts values with the primary key and decrypts them with any known key,
+// so keys can be rotated by adding a new primary key and re-encrypting the data.
+type Protector struct {
+	primary  uint
+	keys     map[uint]cipher.AEAD
+	indexKey []byte
+}
+
+func NewProtector(keys map[uint][]byte, primary uint, indexKey []byte) (*Protector, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("pii: no encryption keys")
+	}
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("pii: primary key %d is not configured", primary)
+	}
+	if len(indexKey) < MinIndexSize {
+		return nil, fmt.Errorf("pii: index key must be at least %d bytes", MinIndexSize)
+	}
+
+	aeads := make(map[uint]cipher.AEAD, len(keys))
+	for id, key := range keys {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("pii: key %d must be %d bytes", id, KeySize)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		aeads[id] = aead
+	}
+
+	return &Protector{primary: primary, keys: aeads, indexKey: indexKey}, nil
+}
+
+// PrimaryKey is the ID of the key new values are encrypted with.
+func (p *Protector) PrimaryKey() uint {
+	return p.primary
+}
+
+// Encrypt returns base64 of nonce and ciphertext.
+func (p *Protector) Encrypt(plaintext string) (string, error) {
+	aead := p.keys[p.primary]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (p *Protector) Decrypt(keyID uint, ciphertext string) (string, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrMalformedData
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("pii: decrypt: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// Index is a blind index of the value, equal values have equal indexes.
+// The field is mixed in, so equal values of different fields are not linkable.
+func (p *Protector) Index(field, value string) string {
+	mac := hmac.New(sha256.New, p.indexKey)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ParseKeys parses comma separated "<id>:<base64 key>" pairs.
+func ParseKeys(s string) (map[uint][]byte, error) {
+	keys := make(map[uint][]byte)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		rawID, rawKey, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("pii: key %q must be in <id>:<base64 key> format", pair)
+		}
+
+		id, err := strconv.ParseUint(rawID, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("pii: invalid key id %q: %w", rawID, err)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("pii: invalid key %d: %w", id, err)
+		}
+
+		keys[uint(id)] = key
+	}
+	return keys, nil
+}
+
+// LatestKey is the greatest key ID, it is used as primary key by default.
+func LatestKey(keys map[uint][]byte) uint {
+	var latest uint
+	for id := range keys {
+		latest = max(latest, id)
+	}
+	return latest
+}
+
+// GenerateKey returns base64 of a random key, suitable for both encryption and index keys.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
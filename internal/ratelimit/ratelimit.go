@@ -0,0 +1,78 @@
+// Package ratelimit limits the rate of requests with token buckets.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New("ratelimit: rate and burst must be positive")
+
+// Limit allows Burst requests at once, refilled at Rate requests per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) Validate() error {
+	if l.Rate <= 0 || l.Burst <= 0 {
+		return ErrInvalidLimit
+	}
+
+	return nil
+}
+
+// fill is the time the bucket takes to refill the given tokens.
+func (l Limit) fill(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / l.Rate * float64(time.Second)))
+}
+
+// Result describes the bucket after taking a token.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token if the request is not allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets, implementations must be safe for concurrent use
+// so buckets can be shared between replicas by an external store.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// bucket is the state of a token bucket.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full is the time the bucket is full again.
+	full time.Time
+}
+
+// take refills the bucket up to now and takes a token if there is one.
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+		b.last = now
+	}
+
+	res := Result{Limit: limit.Burst}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = limit.fill(1 - b.tokens)
+	}
+
+	res.Remaining = int(b.tokens)
+	res.Reset = limit.fill(float64(limit.Burst) - b.tokens)
+
+	return res
+}
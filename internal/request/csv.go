@@ -0,0 +1,43 @@
+package request
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+func DecodeCSV(w http.ResponseWriter, r *http.Request) ([][]string, error) {
+	maxBytes := 1_048_576
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	reader := csv.NewReader(r.Body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		var parseError *csv.ParseError
+
+		switch {
+		case errors.As(err, &parseError):
+			return nil, fmt.Errorf("body contains badly-formed CSV (at line %d)", parseError.Line)
+
+		case err.Error() == "http: request body too large":
+			return nil, fmt.Errorf("body must not be larger than %d bytes", maxBytes)
+
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return nil, errors.New("body contains badly-formed CSV")
+
+		default:
+			return nil, err
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("body must not be empty")
+	}
+
+	return records, nil
+}
@@ -0,0 +1,60 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+const MergePatchContentType = "application/merge-patch+json"
+
+var ErrUnsupportedMediaType = errors.New("content type must be " + MergePatchContentType)
+
+// Field is a field of a JSON merge patch (RFC 7396): absent, explicit null or a value.
+type Field[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+// UnmarshalJSON is not called for absent fields, so Set stays false.
+func (f *Field[T]) UnmarshalJSON(data []byte) error {
+	f.Set = true
+
+	if string(data) == "null" {
+		f.Null = true
+		return nil
+	}
+
+	if err := json.Unmarshal(data, &f.Value); err != nil {
+		// The decoder does not add the field name to errors of nested unmarshalers.
+		var unmarshalTypeError *json.UnmarshalTypeError
+		if errors.As(err, &unmarshalTypeError) {
+			return fmt.Errorf("body contains incorrect JSON type %s instead of %s", unmarshalTypeError.Value, unmarshalTypeError.Type.Kind())
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Ptr returns the value, nil if the field is absent or null.
+func (f Field[T]) Ptr() *T {
+	if !f.Set || f.Null {
+		return nil
+	}
+	return &f.Value
+}
+
+// DecodeMergePatch decodes the merge patch body, unknown fields are not allowed.
+// It returns ErrUnsupportedMediaType for other content types.
+func DecodeMergePatch(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != MergePatchContentType {
+		return ErrUnsupportedMediaType
+	}
+
+	return DecodeJSONStrict(w, r, dst)
+}
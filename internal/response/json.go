@@ -12,6 +12,19 @@ func JSON(w http.ResponseWriter, status int, data any) error {
 }
 
 func JSONWithHeaders(w http.ResponseWriter, status int, data any, headers http.Header) error {
+	return write(w, status, "application/json; charset=utf-8", data, headers)
+}
+
+// Problem writes data as RFC 7807 problem details.
+func Problem(w http.ResponseWriter, status int, data any) error {
+	return ProblemWithHeaders(w, status, data, nil)
+}
+
+func ProblemWithHeaders(w http.ResponseWriter, status int, data any, headers http.Header) error {
+	return write(w, status, "application/problem+json; charset=utf-8", data, headers)
+}
+
+func write(w http.ResponseWriter, status int, contentType string, data any, headers http.Header) error {
 	js, err := json.MarshalIndent(data, "", "\t")
 	if err != nil {
 		return err
@@ -23,7 +36,7 @@ func JSONWithHeaders(w http.ResponseWriter, status int, data any, headers http.H
 		w.Header()[key] = value
 	}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(status)
 	w.Write(js)
 
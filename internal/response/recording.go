@@ -0,0 +1,22 @@
+package response
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// RecordingResponseWriter writes the response and keeps a copy of its body.
+type RecordingResponseWriter struct {
+	*MetricsResponseWriter
+	Body bytes.Buffer
+}
+
+func NewRecordingResponseWriter(w http.ResponseWriter) *RecordingResponseWriter {
+	return &RecordingResponseWriter{MetricsResponseWriter: NewMetricsResponseWriter(w)}
+}
+
+func (rw *RecordingResponseWriter) Write(b []byte) (int, error) {
+	n, err := rw.MetricsResponseWriter.Write(b)
+	rw.Body.Write(b[:n])
+	return n, err
+}
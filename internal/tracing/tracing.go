@@ -0,0 +1,73 @@
+// Package tracing configures OpenTelemetry tracing with W3C trace context propagation.
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const TracerName = "github.com/protomem/time-tracker"
+
+type Config struct {
+	// Enabled exports spans with OTLP over HTTP, the exporter is configured by
+	// standard OTEL_EXPORTER_OTLP_* environment variables.
+	Enabled     bool
+	ServiceName string
+	Version     string
+	SampleRatio float64
+}
+
+// Provider creates spans and exports them if tracing is enabled.
+// Spans are created even if tracing is disabled, so that trace IDs can be used in logs.
+type Provider struct {
+	*sdktrace.TracerProvider
+}
+
+func New(ctx context.Context, cfg Config) (*Provider, error) {
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(cfg.ServiceName),
+			semconv.ServiceVersion(cfg.Version),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+
+	if cfg.Enabled {
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts,
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		)
+	} else {
+		opts = append(opts, sdktrace.WithSampler(sdktrace.NeverSample()))
+	}
+
+	provider := &Provider{TracerProvider: sdktrace.NewTracerProvider(opts...)}
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider, nil
+}
+
+// Tracer returns the application tracer of the provider.
+func Tracer(provider trace.TracerProvider) trace.Tracer {
+	return provider.Tracer(TracerName)
+}
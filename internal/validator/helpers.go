@@ -1,9 +1,9 @@
 package validator
 
 import (
+	"fmt"
 	"net/url"
 	"regexp"
-	"strconv"
 	"strings"
 	"unicode/utf8"
 
@@ -86,7 +86,19 @@ func IsURL(value string) bool {
 	return u.Scheme != "" && u.Host != ""
 }
 
-func DigitsInNumber(num int, n int) bool {
-	numStr := strconv.Itoa(num)
-	return len(numStr) == n
+// DigitsInNumber reports whether num consists of exactly n decimal digits.
+// Strings are checked as is, so leading zeros are counted.
+func DigitsInNumber[T ~int | ~string](num T, n int) bool {
+	numStr := fmt.Sprint(num)
+	if len(numStr) != n {
+		return false
+	}
+
+	for _, r := range numStr {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
 }